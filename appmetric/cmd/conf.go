@@ -4,11 +4,36 @@ import (
 	"encoding/json"
 	"github.com/golang/glog"
 	"io/ioutil"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
 )
 
 type metricConf struct {
 	Address string `json:"targetAddress,omitempty"`
 	Port    string `json:"metricPort,omitempty"`
+
+	// auth and TLS settings to access the prometheus server
+	Username           string `json:"username,omitempty"`
+	Password           string `json:"password,omitempty"`
+	BearerTokenFile    string `json:"bearerTokenFile,omitempty"`
+	CAFile             string `json:"caFile,omitempty"`
+	CertFile           string `json:"certFile,omitempty"`
+	KeyFile            string `json:"keyFile,omitempty"`
+	ServerName         string `json:"serverName,omitempty"`
+	InsecureSkipVerify *bool  `json:"insecureSkipVerify,omitempty"`
+
+	// settings of a multi-tenant query frontend
+	TenantID     string            `json:"tenantID,omitempty"`
+	TenantHeader string            `json:"tenantHeader,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Params       map[string]string `json:"params,omitempty"`
+
+	// retry and circuit breaker of the queries
+	Retry   *prometheus.RetryConfig   `json:"retry,omitempty"`
+	Breaker *prometheus.BreakerConfig `json:"breaker,omitempty"`
+
+	// multiple prometheus servers; if set, targetAddress and the settings above are ignored
+	Servers []*prometheus.ClientConfig `json:"prometheusServers,omitempty"`
 }
 
 type wrapConf struct {
@@ -5,12 +5,15 @@ import (
 	"github.com/golang/glog"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"fmt"
 	"github.com/turbonomic/prometurbo/appmetric/pkg/addon"
 	ali "github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
 	"github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
 	"github.com/turbonomic/prometurbo/appmetric/pkg/server"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
 )
 
 const (
@@ -18,21 +21,169 @@ const (
 	defaultSampleDuration = "3m"
 )
 
+// the getters of the exporters, besides Istio, to get the applications, hosts and containers;
+// the optional getters are added only if their categories are enabled by --enableGetters
+var appGetters = []struct {
+	category string
+	name     string
+	optional bool
+}{
+	{addon.RedisGetterCategory, "redis.app.metric", false},
+	{addon.CassandraGetterCategory, "cassandra.app.metric", false},
+	{addon.MySQLGetterCategory, "mysql.app.metric", true},
+	{addon.PostgresGetterCategory, "postgres.app.metric", true},
+	{addon.MongoDBGetterCategory, "mongodb.app.metric", true},
+	{addon.KafkaGetterCategory, "kafka.app.metric", true},
+	{addon.JVMGetterCategory, "jvm.app.metric", true},
+	{addon.NodeGetterCategory, "node.vm.metric", true},
+	{addon.CAdvisorGetterCategory, "cadvisor.container.metric", true},
+	{addon.GRPCGetterCategory, "grpc.app.metric", true},
+}
+
+// the getters of the services, besides Istio
+var vappGetters = []struct {
+	category string
+	name     string
+	optional bool
+}{
+	{addon.NginxIngressVAppGetterCategory, "nginx.vapp.metric", true},
+}
+
 var (
 	prometheusHost string
 	port           int
 	configfname    string
 	sampleDuration string
+	getterConfig   string
+	getterTimeout  time.Duration
+	mergePolicy    string
+	preferGetters  string
+	scrapeInterval time.Duration
+	istioV2        bool
+
+	// comma separated categories of the optional getters to enable
+	enableGetters string
+
+	// latency of the histogram metrics
+	latencyQuantile       float64
+	latencyLabelQuantiles string
+
+	// the entity label matched with the Kafka consumer groups
+	kafkaConsumerLabel string
+
+	// report the metrics of every gRPC method
+	grpcMethodKeys bool
+
+	// the entity type of the targets probed by blackbox_exporter, and the label of the probes identifying the target
+	blackboxEntityType  string
+	blackboxTargetLabel string
+
+	// auth and TLS settings of the prometheus client
+	clientConf = prometheus.NewClientConfig("")
+
+	// the prometheus servers to query
+	serverConfs []*prometheus.ClientConfig
+
+	// comma separated key=value pairs
+	promHeaders string
+	promParams  string
 )
 
 func parseFlags() {
-	flag.StringVar(&prometheusHost, "promUrl", "", "the address of prometheus server")
+	flag.StringVar(&prometheusHost, "promUrl", "", "the address of prometheus server; comma separated addresses of HA replicas")
 	flag.IntVar(&port, "port", 0, "port to expose metrics (default 8081)")
 	flag.StringVar(&configfname, "config", "", "path of the config file")
 	flag.StringVar(&sampleDuration, "sampleDuration", defaultSampleDuration, "the sample duration for prometheus query")
+	flag.DurationVar(&getterTimeout, "getterTimeout", ali.DefaultGetterTimeout, "the deadline of each entity getter")
+	flag.StringVar(&mergePolicy, "mergePolicy", ali.MergePrefer, "how to merge the metrics of the same entity from different getters: prefer, max or sum")
+	flag.StringVar(&preferGetters, "preferGetters", "", "comma separated getter names in the order of priority, used by the prefer merge policy, e.g., istio.vapp.metric,nginx.vapp.metric if both report the services")
+	flag.DurationVar(&scrapeInterval, "scrapeInterval", 0, "the interval to refresh metrics in background; 0 to query prometheus on every request")
+	flag.BoolVar(&istioV2, "istioTelemetryV2", false, "get Istio metrics from the standard telemetry v2 metrics, instead of the custom Mixer metrics")
+	flag.StringVar(&enableGetters, "enableGetters", "", "comma separated categories of the optional getters to enable, e.g., MySQL,Node; none by default")
+	flag.Float64Var(&latencyQuantile, "latencyQuantile", 0, "the quantile of the histogram reported as latency, e.g., 0.95; 0 to report the mean latency")
+	flag.StringVar(&latencyLabelQuantiles, "latencyLabelQuantiles", "", "comma separated quantiles of the histogram reported as labels for comparison, e.g., 0,0.5,0.99; 0 for the mean")
+	flag.StringVar(&kafkaConsumerLabel, "kafkaConsumerLabel", addon.DefaultKafkaConsumerLabel, "the label of the applications matched with the Kafka consumer groups to attach the consumer lag; empty to disable it")
+	flag.BoolVar(&grpcMethodKeys, "grpcMethodKeys", false, "report TPS and latency of every gRPC method as the commodities keyed by the method")
+	flag.StringVar(&blackboxEntityType, "blackboxEntityType", "", "the entity type of the targets probed by blackbox_exporter: VIRTUAL_APPLICATION or BUSINESS_APPLICATION; empty (default) to disable the blackbox getter")
+	flag.StringVar(&blackboxTargetLabel, "blackboxTargetLabel", addon.DefaultBlackboxTargetLabel, "the label of the blackbox probes whose value is the UID of the probed target")
+	flag.StringVar(&getterConfig, "getterConfig", "", "path of the config file defining additional entity getters")
+	flag.StringVar(&clientConf.Username, "promUsername", "", "the username of basic auth to access prometheus server")
+	flag.StringVar(&clientConf.Password, "promPassword", "", "the password of basic auth to access prometheus server")
+	flag.StringVar(&clientConf.BearerTokenFile, "promBearerTokenFile", "", "path of the bearer token file to access prometheus server")
+	flag.StringVar(&clientConf.TLS.CAFile, "promCAFile", "", "path of the CA bundle to verify the certificate of prometheus server")
+	flag.StringVar(&clientConf.TLS.CertFile, "promCertFile", "", "path of the client certificate file")
+	flag.StringVar(&clientConf.TLS.KeyFile, "promKeyFile", "", "path of the client key file")
+	flag.StringVar(&clientConf.TLS.ServerName, "promServerName", "", "the server name to verify the certificate of prometheus server")
+	flag.BoolVar(&clientConf.TLS.InsecureSkipVerify, "promInsecureSkipVerify", false, "skip verifying the certificate of prometheus server; it was skipped by the earlier versions, so set it to true, or set promCAFile, for a self-signed certificate")
+	flag.StringVar(&clientConf.TenantID, "promTenantID", "", "the tenant ID of a multi-tenant query frontend, such as Cortex, Thanos or Mimir")
+	flag.StringVar(&clientConf.TenantHeader, "promTenantHeader", prometheus.DefaultTenantHeader, "the header to send the tenant ID")
+	flag.StringVar(&promHeaders, "promHeaders", "", "comma separated static headers sent to prometheus server, e.g., X-Foo=bar,X-Baz=qux")
+	flag.StringVar(&promParams, "promParams", "", "comma separated extra query parameters, e.g., dedup=true,partial_response=true")
+	flag.IntVar(&clientConf.Retry.MaxRetries, "promMaxRetries", prometheus.DefaultMaxRetries, "max retries of a query failed by transient errors; 0 to disable retry")
+	flag.StringVar(&clientConf.Retry.Backoff, "promBackoff", prometheus.DefaultBackoff, "the backoff before the first retry, doubled for every retry")
+	flag.StringVar(&clientConf.Retry.MaxBackoff, "promMaxBackoff", prometheus.DefaultMaxBackoff, "the max backoff between retries")
+	flag.IntVar(&clientConf.Breaker.Threshold, "promBreakerThreshold", prometheus.DefaultBreakerThreshold, "consecutive failed queries to open the circuit breaker of a prometheus server; 0 to disable it")
+	flag.StringVar(&clientConf.Breaker.Cooldown, "promBreakerCooldown", prometheus.DefaultBreakerCooldown, "how long the open circuit breaker fails the queries fast")
 	flag.Parse()
 }
 
+// setClientConf sets the auth and TLS settings from the config file, if they are not set by flags
+func setClientConf(mconf *metricConf) {
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+
+	setString := func(name string, v *string, confValue string) {
+		if !setFlags[name] && len(confValue) > 0 {
+			*v = confValue
+		}
+	}
+
+	setString("promUsername", &clientConf.Username, mconf.Username)
+	setString("promPassword", &clientConf.Password, mconf.Password)
+	setString("promBearerTokenFile", &clientConf.BearerTokenFile, mconf.BearerTokenFile)
+	setString("promCAFile", &clientConf.TLS.CAFile, mconf.CAFile)
+	setString("promCertFile", &clientConf.TLS.CertFile, mconf.CertFile)
+	setString("promKeyFile", &clientConf.TLS.KeyFile, mconf.KeyFile)
+	setString("promServerName", &clientConf.TLS.ServerName, mconf.ServerName)
+	if !setFlags["promInsecureSkipVerify"] && mconf.InsecureSkipVerify != nil {
+		clientConf.TLS.InsecureSkipVerify = *mconf.InsecureSkipVerify
+	}
+	setString("promTenantID", &clientConf.TenantID, mconf.TenantID)
+	setString("promTenantHeader", &clientConf.TenantHeader, mconf.TenantHeader)
+	if !setFlags["promHeaders"] && len(mconf.Headers) > 0 {
+		clientConf.Headers = mconf.Headers
+	}
+	if !setFlags["promParams"] && len(mconf.Params) > 0 {
+		clientConf.Params = mconf.Params
+	}
+	if !setFlags["promMaxRetries"] && !setFlags["promBackoff"] && !setFlags["promMaxBackoff"] && mconf.Retry != nil {
+		clientConf.Retry = *mconf.Retry
+	}
+	if !setFlags["promBreakerThreshold"] && !setFlags["promBreakerCooldown"] && mconf.Breaker != nil {
+		clientConf.Breaker = *mconf.Breaker
+	}
+}
+
+// parseKeyValues parses the comma separated key=value pairs
+func parseKeyValues(s string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); len(pair) < 1 {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) < 1 {
+			return nil, fmt.Errorf("Invalid key=value pair: %v", pair)
+		}
+		result[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return result, nil
+}
+
 func getJobs(mclient *prometheus.RestClient) {
 	msg, err := mclient.GetJobs()
 	if err != nil {
@@ -56,6 +207,16 @@ func parseConf() error {
 		return err
 	}
 
+	var err error
+	if clientConf.Headers, err = parseKeyValues(promHeaders); err != nil {
+		glog.Errorf("Failed to parse promHeaders: %v", err)
+		return err
+	}
+	if clientConf.Params, err = parseKeyValues(promParams); err != nil {
+		glog.Errorf("Failed to parse promParams: %v", err)
+		return err
+	}
+
 	if len(configfname) > 0 {
 		mconf, err := readConfig(configfname)
 		if err != nil {
@@ -65,8 +226,11 @@ func parseConf() error {
 
 		if len(prometheusHost) < 1 {
 			prometheusHost = mconf.Address
+			serverConfs = mconf.Servers
 		}
 
+		setClientConf(mconf)
+
 		if port < 1 && len(mconf.Port) > 1 {
 			port, err = strconv.Atoi(mconf.Port)
 			if err != nil {
@@ -76,7 +240,7 @@ func parseConf() error {
 		}
 	}
 
-	if len(prometheusHost) < 1 {
+	if len(prometheusHost) < 1 && len(serverConfs) < 1 {
 		err := fmt.Errorf("Failed to get prometheus server address")
 		glog.Error(err.Error())
 		return err
@@ -86,6 +250,155 @@ func parseConf() error {
 		port = defaultPort
 	}
 
+	if len(serverConfs) < 1 {
+		for _, addr := range strings.Split(prometheusHost, ",") {
+			if addr = strings.TrimSpace(addr); len(addr) > 0 {
+				conf := *clientConf
+				conf.Address = addr
+				serverConfs = append(serverConfs, &conf)
+			}
+		}
+	}
+	return nil
+}
+
+// getSources groups the prometheus servers by their labels:
+// the servers with the same label are HA replicas, whose results are deduplicated.
+func getSources() ([]*ali.Source, error) {
+	labels := []string{}
+	replicas := make(map[string][]*prometheus.RestClient)
+	for _, conf := range serverConfs {
+		pclient, err := prometheus.NewRestClientWithConfig(conf)
+		if err != nil {
+			glog.Errorf("Failed to generate client for %v: %v", conf.Address, err)
+			return nil, err
+		}
+		test_prometheus(pclient)
+
+		if _, ok := replicas[conf.Label]; !ok {
+			labels = append(labels, conf.Label)
+		}
+		replicas[conf.Label] = append(replicas[conf.Label], pclient)
+	}
+
+	sources := []*ali.Source{}
+	for _, label := range labels {
+		if len(replicas[label]) == 1 {
+			sources = append(sources, ali.NewSource(label, replicas[label][0]))
+			continue
+		}
+		sources = append(sources, ali.NewSource(label, prometheus.NewReplicaClient(replicas[label])))
+	}
+	glog.V(2).Infof("%d prometheus sources from %d servers", len(sources), len(serverConfs))
+
+	return sources, nil
+}
+
+func getMergePolicy() (*ali.MergePolicy, error) {
+	preferred := []string{}
+	for _, name := range strings.Split(preferGetters, ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			preferred = append(preferred, name)
+		}
+	}
+
+	return ali.NewMergePolicy(mergePolicy, preferred)
+}
+
+func getLatencyOption() (*addon.LatencyOption, error) {
+	quantiles := []float64{}
+	for _, v := range strings.Split(latencyLabelQuantiles, ",") {
+		if v = strings.TrimSpace(v); len(v) < 1 {
+			continue
+		}
+
+		q, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid latency quantile %v: %v", v, err)
+		}
+		quantiles = append(quantiles, q)
+	}
+
+	return addon.NewLatencyOption(latencyQuantile, quantiles)
+}
+
+// getEnabledGetters returns the categories of the optional getters enabled by --enableGetters
+func getEnabledGetters() (map[string]bool, error) {
+	optional := make(map[string]bool)
+	for _, g := range appGetters {
+		optional[g.category] = g.optional
+	}
+	for _, g := range vappGetters {
+		optional[g.category] = g.optional
+	}
+
+	enabled := make(map[string]bool)
+	for _, category := range strings.Split(enableGetters, ",") {
+		if category = strings.TrimSpace(category); len(category) < 1 {
+			continue
+		}
+		if !optional[category] {
+			return nil, fmt.Errorf("Unknown optional getter category: %v", category)
+		}
+		enabled[category] = true
+	}
+
+	return enabled, nil
+}
+
+// addBlackboxGetter creates the getter of blackbox_exporter:
+// the virtual applications are served as service metrics, the business applications are served as pod metrics.
+func addBlackboxGetter(factory *addon.GetterFactory, appClient, vappClient *ali.Alligator) error {
+	etype, ok := proto.EntityDTO_EntityType_value[blackboxEntityType]
+	if !ok {
+		return fmt.Errorf("Unknown entity type: %v", blackboxEntityType)
+	}
+	factory.SetBlackboxTarget(proto.EntityDTO_EntityType(etype), blackboxTargetLabel)
+
+	getter, err := factory.CreateEntityGetter(addon.BlackboxGetterCategory, "blackbox.target.metric", sampleDuration)
+	if err != nil {
+		return err
+	}
+
+	client := appClient
+	if proto.EntityDTO_EntityType(etype) == proto.EntityDTO_VIRTUAL_APPLICATION {
+		client = vappClient
+	}
+	client.AddGetter(getter)
+	glog.V(2).Infof("Added %v getter: %+v", addon.BlackboxGetterCategory, getter)
+
+	return nil
+}
+
+// addConfigGetters creates the getters defined in the getter config file:
+// getters of VIRTUAL_APPLICATION are served as service metrics, others are served as pod metrics.
+func addConfigGetters(factory *addon.GetterFactory, appClient, vappClient *ali.Alligator) error {
+	conf, err := addon.ReadEntityGetterConf(getterConfig)
+	if err != nil {
+		return err
+	}
+
+	for _, def := range conf.Getters {
+		getter, err := factory.CreateConfigEntityGetter(def, sampleDuration)
+		if err != nil {
+			return err
+		}
+
+		client := appClient
+		if etype, _ := def.GetEntityType(); etype == proto.EntityDTO_VIRTUAL_APPLICATION {
+			client = vappClient
+		}
+
+		if !client.AddGetter(getter) {
+			return fmt.Errorf("duplicated getter name: %v", def.Name)
+		}
+
+		if timeout, _ := def.GetTimeout(); timeout > 0 {
+			client.SetGetterTimeout(def.Name, timeout)
+		}
+		glog.V(2).Infof("Added getter %v from config file", def.Name)
+	}
+
 	return nil
 }
 
@@ -105,49 +418,112 @@ func main() {
 		return
 	}
 
-	pclient, err := prometheus.NewRestClient(prometheusHost)
+	sources, err := getSources()
 	if err != nil {
 		glog.Fatalf("Failed to generate client: %v", err)
 	}
-	//mclient.SetUser("", "")
-	test_prometheus(pclient)
 
-	factory := addon.NewGetterFactory()
+	policy, err := getMergePolicy()
+	if err != nil {
+		glog.Errorf("Failed to get merge policy: %v", err)
+		return
+	}
 
-	//1. Application Metrics
-	appClient := ali.NewAlligator(pclient)
-	istioGetter, err := factory.CreateEntityGetter(addon.IstioGetterCategory, "istio.app.metric", sampleDuration)
+	latency, err := getLatencyOption()
 	if err != nil {
-		glog.Errorf("Failed to create Istio App getter: %v", err)
+		glog.Errorf("Failed to get latency option: %v", err)
 		return
 	}
-	appClient.AddGetter(istioGetter)
 
-	redisGetter, err := factory.CreateEntityGetter(addon.RedisGetterCategory, "redis.app.metric", sampleDuration)
+	enabled, err := getEnabledGetters()
 	if err != nil {
-		glog.Errorf("Failed to create Redis App getter: %v", err)
+		glog.Errorf("Failed to get the enabled getters: %v", err)
 		return
 	}
-	appClient.AddGetter(redisGetter)
 
-	cassandraGetter, err := factory.CreateEntityGetter(addon.CassandraGetterCategory, "cassandra.app.metric", sampleDuration)
+	factory := addon.NewGetterFactory()
+	factory.SetLatencyOption(latency)
+	factory.SetKafkaConsumerLabel(kafkaConsumerLabel)
+	factory.SetGRPCMethodKeys(grpcMethodKeys)
+
+	istioCategory, istioVAppCategory := addon.IstioGetterCategory, addon.IstioVAppGetterCategory
+	if istioV2 {
+		istioCategory, istioVAppCategory = addon.IstioV2GetterCategory, addon.IstioV2VAppGetterCategory
+	}
+
+	//1. Application Metrics
+	appClient := ali.NewAlligator(sources...)
+	appClient.SetTimeout(getterTimeout)
+	appClient.SetMergePolicy(policy)
+	istioGetter, err := factory.CreateEntityGetter(istioCategory, "istio.app.metric", sampleDuration)
 	if err != nil {
-		glog.Errorf("Failed to create Cassandra App getter: %v", err)
+		glog.Errorf("Failed to create Istio App getter: %v", err)
 		return
 	}
-	glog.V(2).Infof("Added cassandraGetter: %+v", cassandraGetter)
-	appClient.AddGetter(cassandraGetter)
+	appClient.AddGetter(istioGetter)
+
+	for _, g := range appGetters {
+		if g.optional && !enabled[g.category] {
+			continue
+		}
+		getter, err := factory.CreateEntityGetter(g.category, g.name, sampleDuration)
+		if err != nil {
+			glog.Errorf("Failed to create %v App getter: %v", g.category, err)
+			return
+		}
+		glog.V(2).Infof("Added %v getter: %+v", g.category, getter)
+		appClient.AddGetter(getter)
+	}
 
 	//2. Virtual Application Metrics
-	vappClient := ali.NewAlligator(pclient)
-	vappGetter, err := factory.CreateEntityGetter(addon.IstioVAppGetterCategory, "istio.vapp.metric", sampleDuration)
+	vappClient := ali.NewAlligator(sources...)
+	vappClient.SetTimeout(getterTimeout)
+	vappClient.SetMergePolicy(policy)
+	vappGetter, err := factory.CreateEntityGetter(istioVAppCategory, "istio.vapp.metric", sampleDuration)
 	if err != nil {
 		glog.Errorf("Failed to create Istio VApp getter: %v", err)
 		return
 	}
 	vappClient.AddGetter(vappGetter)
 
-	s := server.NewMetricServer(port, appClient, vappClient)
+	for _, g := range vappGetters {
+		if g.optional && !enabled[g.category] {
+			continue
+		}
+		getter, err := factory.CreateEntityGetter(g.category, g.name, sampleDuration)
+		if err != nil {
+			glog.Errorf("Failed to create %v VApp getter: %v", g.category, err)
+			return
+		}
+		glog.V(2).Infof("Added %v getter: %+v", g.category, getter)
+		vappClient.AddGetter(getter)
+	}
+
+	//3. Targets probed by blackbox_exporter
+	if len(blackboxEntityType) > 0 {
+		if err := addBlackboxGetter(factory, appClient, vappClient); err != nil {
+			glog.Errorf("Failed to create %v getter: %v", addon.BlackboxGetterCategory, err)
+			return
+		}
+	}
+
+	//4. Entity getters defined in the getter config file
+	if len(getterConfig) > 0 {
+		if err := addConfigGetters(factory, appClient, vappClient); err != nil {
+			glog.Errorf("Failed to add getters from config file %v: %v", getterConfig, err)
+			return
+		}
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	appScraper := ali.NewScraper(appClient, scrapeInterval)
+	go appScraper.Run(stop)
+	vappScraper := ali.NewScraper(vappClient, scrapeInterval)
+	go vappScraper.Run(stop)
+
+	s := server.NewMetricServer(port, appScraper, vappScraper)
 	s.Run()
 	return
 }
@@ -0,0 +1,136 @@
+package addon
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// the metrics of the probes of blackbox_exporter
+const (
+	blackbox_PROBE_SUCCESS  = "probe_success"
+	blackbox_PROBE_DURATION = "probe_duration_seconds"
+
+	// the probed target is the "instance" label, if relabeled from the "target" parameter as suggested by blackbox_exporter
+	DefaultBlackboxTargetLabel = instanceLabel
+	DefaultBlackboxEntityType  = inter.VAppEntity
+
+	// the key of the probe latency, told apart from the latency reported by other getters, e.g., the in-mesh one of Istio
+	BlackboxProbeKey = "probe"
+)
+
+// BlackboxEntityGetter builds the endpoints probed by blackbox_exporter, identified by the value of the target label
+// of the probes, as the virtual applications or the business applications:
+// the mean duration of the probes in milliseconds as the latency keyed by "probe", and the ratio of the successful probes
+// as availability. The target label can be relabeled to the UID of the entity reported by other getters,
+// e.g., "<namespace>/<service>" of the services, to attach the user-facing metrics to the entity without overriding its own latency.
+type BlackboxEntityGetter struct {
+	name        string
+	du          string
+	entityType  proto.EntityDTO_EntityType
+	targetLabel string
+}
+
+// ensure BlackboxEntityGetter implement the requisite interfaces
+var _ alligator.EntityMetricGetter = &BlackboxEntityGetter{}
+
+func NewBlackboxEntityGetter(name, du string, entityType proto.EntityDTO_EntityType, targetLabel string) (*BlackboxEntityGetter, error) {
+	if entityType != inter.VAppEntity && entityType != inter.BusinessAppEntity {
+		return nil, fmt.Errorf("Unsupported entity type %v of the probed targets", entityType)
+	}
+
+	if len(targetLabel) < 1 {
+		return nil, fmt.Errorf("The target label of the probes is empty")
+	}
+
+	return &BlackboxEntityGetter{
+		name:        name,
+		du:          du,
+		entityType:  entityType,
+		targetLabel: targetLabel,
+	}, nil
+}
+
+func (g *BlackboxEntityGetter) Name() string {
+	return g.name
+}
+
+func (g *BlackboxEntityGetter) Category() string {
+	return BlackboxGetterCategory
+}
+
+func (g *BlackboxEntityGetter) GetEntityMetric(client xfire.MetricClient) ([]*inter.EntityMetric, error) {
+	result := []*inter.EntityMetric{}
+	midResult := make(map[string]*inter.EntityMetric)
+
+	//1. get the availability, which is exported by every probe
+	query := xfire.NewBasicInput()
+	query.SetQuery(g.getAvgExp(blackbox_PROBE_SUCCESS))
+	successDat, err := client.GetMetrics(query)
+	if err != nil {
+		glog.Errorf("Failed to get blackbox probe success metrics: %v", err)
+		return result, err
+	}
+	for _, dat := range successDat {
+		if entity := g.getEntity(dat, midResult); entity != nil {
+			entity.SetAttribute(inter.Availability, dat.GetValue())
+		}
+	}
+
+	//2. get Latency data
+	query = xfire.NewBasicInput()
+	query.SetQuery("1000*" + g.getAvgExp(blackbox_PROBE_DURATION))
+	latencyDat, err := client.GetMetrics(query)
+	if err != nil {
+		glog.Errorf("Failed to get blackbox probe duration metrics: %v", err)
+		return result, err
+	}
+	for _, dat := range latencyDat {
+		if entity := g.getEntity(dat, midResult); entity != nil {
+			entity.SetKeyedMetric(BlackboxProbeKey, inter.LatencyType, dat.GetValue())
+		}
+	}
+
+	glog.V(4).Infof("len(Availability)=%d, len(Latency)=%d", len(successDat), len(latencyDat))
+
+	//3. reform map to list
+	for _, v := range midResult {
+		result = append(result, v)
+	}
+
+	return result, nil
+}
+
+// getEntity returns the entity of the probed target, which is created if not exists
+func (g *BlackboxEntityGetter) getEntity(dat xfire.MetricData, result map[string]*inter.EntityMetric) *inter.EntityMetric {
+	metric, ok := dat.(*xfire.BasicMetricData)
+	if !ok {
+		glog.Errorf("Type assertion failed for blackbox probe metric.")
+		return nil
+	}
+
+	target := metric.Labels[g.targetLabel]
+	if len(target) < 1 {
+		glog.V(3).Infof("Skip blackbox metric %v: label %v is not found", metric.Labels, g.targetLabel)
+		return nil
+	}
+
+	if entity, ok := result[target]; ok {
+		return entity
+	}
+
+	entity := inter.NewEntityMetric(target, g.entityType)
+	entity.SetLabel(inter.Name, target)
+	entity.SetLabel(g.targetLabel, target)
+	entity.SetLabel(inter.Category, g.Category())
+	result[target] = entity
+	return entity
+}
+
+// exp = avg by (instance) (avg_over_time(probe_success[3m]))
+func (g *BlackboxEntityGetter) getAvgExp(metric string) string {
+	return fmt.Sprintf("avg by (%v) (avg_over_time(%v[%v]))", g.targetLabel, metric, g.du)
+}
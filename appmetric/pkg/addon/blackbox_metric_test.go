@@ -0,0 +1,75 @@
+package addon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+func TestNewBlackboxEntityGetter(t *testing.T) {
+	if _, err := NewBlackboxEntityGetter("blackbox.target.metric", "3m", inter.AppEntity, "instance"); err == nil {
+		t.Errorf("Entity type %v should not be supported", inter.AppEntity)
+	}
+
+	if _, err := NewBlackboxEntityGetter("blackbox.target.metric", "3m", inter.VAppEntity, ""); err == nil {
+		t.Errorf("Empty target label should not be accepted")
+	}
+}
+
+func TestBlackboxEntityGetter_GetEntityMetric(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		if !strings.Contains(query, "by (service)") {
+			t.Errorf("Unexpected query: %v", query)
+		}
+
+		if strings.Contains(query, blackbox_PROBE_DURATION) {
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"service":"shop/frontend"},"value":[1530000000,"120.5"]},
+				{"metric":{"service":"shop/checkout"},"value":[1530000000,"300"]}]}}`))
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"service":"shop/frontend"},"value":[1530000000,"1"]},
+			{"metric":{"service":"shop/checkout"},"value":[1530000000,"0.75"]},
+			{"metric":{"job":"blackbox"},"value":[1530000000,"1"]}]}}`))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+
+	factory := NewGetterFactory()
+	factory.SetBlackboxTarget(inter.BusinessAppEntity, "service")
+	g, err := factory.CreateEntityGetter(BlackboxGetterCategory, "blackbox.target.metric", "3m")
+	if err != nil {
+		t.Fatalf("Failed to create getter: %v", err)
+	}
+
+	result, err := g.GetEntityMetric(client)
+	if err != nil || len(result) != 2 {
+		t.Fatalf("Failed to get entity metrics: %+v, %v", result, err)
+	}
+
+	expected := map[string][]float64{
+		"shop/frontend": {120.5, 1},
+		"shop/checkout": {300, 0.75},
+	}
+	for _, e := range result {
+		v, ok := expected[e.UID]
+		if !ok || e.Type != inter.BusinessAppEntity || e.Labels["service"] != e.UID || e.Labels[inter.Category] != BlackboxGetterCategory {
+			t.Errorf("Wrong entity: %+v", e)
+			continue
+		}
+		// the probe latency is keyed, not to be merged with the latency reported by other getters
+		if len(e.Metrics) != 0 || e.KeyedMetrics[BlackboxProbeKey][inter.LatencyType] != v[0] || e.Attributes[inter.Availability] != v[1] {
+			t.Errorf("Wrong metrics of %v: %+v, %+v, %+v", e.UID, e.Metrics, e.KeyedMetrics, e.Attributes)
+		}
+	}
+}
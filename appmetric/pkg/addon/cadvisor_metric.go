@@ -0,0 +1,304 @@
+package addon
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+	"strings"
+)
+
+// the container metrics of cAdvisor, scraped from the kubelets, and the pod IPs of kube-state-metrics
+const (
+	cadvisor_CPU_USAGE         = "container_cpu_usage_seconds_total"
+	cadvisor_MEMORY_WORKING    = "container_memory_working_set_bytes"
+	cadvisor_CPU_QUOTA         = "container_spec_cpu_quota"
+	cadvisor_CPU_PERIOD        = "container_spec_cpu_period"
+	cadvisor_MEMORY_LIMIT      = "container_spec_memory_limit_bytes"
+	cadvisor_CFS_PERIODS       = "container_cpu_cfs_periods_total"
+	cadvisor_CFS_THROTTLED     = "container_cpu_cfs_throttled_periods_total"
+	cadvisor_MACHINE_CPU_CORES = "machine_cpu_cores"
+	cadvisor_MACHINE_MEMORY    = "machine_memory_bytes"
+	ksm_POD_INFO               = "kube_pod_info"
+
+	cadvisorNamespace = "namespace"
+	cadvisorPod       = "pod"
+	cadvisorContainer = "container"
+	ksmPodIP          = "pod_ip"
+
+	// the containers, without the cgroups of the pods ("") and the pause containers ("POD")
+	cadvisorContainerSelector = `container!="",container!="POD"`
+)
+
+// CAdvisorEntityGetter builds the containers (Container) and pods (ContainerPod) from the metrics of cAdvisor,
+// for the clusters where kubeturbo is not deployed. The containers are identified by "<namespace>/<pod>/<container>",
+// and the pods by "<namespace>/<pod>", with the pod IP from kube-state-metrics as the stitching label of the pods;
+// the containers, sharing the IP of their pod, have it as the "pod_ip" label instead:
+// VCPU in millicores and VMEM in KB, with the capacities of the limits, or the ones of the node if not limited;
+// and the percentage of the throttled CFS periods as the "cpu_throttling" attribute.
+// The pods sum up the usages and capacities of their containers, capped by the node.
+type CAdvisorEntityGetter struct {
+	name    string
+	du      string
+	queries []*instanceQuery
+}
+
+// ensure CAdvisorEntityGetter implement the requisite interfaces
+var _ alligator.EntityMetricGetter = &CAdvisorEntityGetter{}
+
+func NewCAdvisorEntityGetter(name, du string) *CAdvisorEntityGetter {
+	return &CAdvisorEntityGetter{
+		name: name,
+		du:   du,
+		queries: []*instanceQuery{
+			{ctype: inter.VCPUType, query: getCAdvisorCPUExp(du), required: true},
+			{ctype: inter.VMemType, query: sumByContainer(cadvisor_MEMORY_WORKING, "") + " / 1024"},
+			{ctype: inter.VCPUType, query: getCAdvisorCPULimitExp(), capacity: true},
+			{ctype: inter.VMemType, query: sumByContainer(cadvisor_MEMORY_LIMIT, " > 0") + " / 1024", capacity: true},
+			{attribute: inter.CPUThrottling, query: getCAdvisorThrottlingExp(du)},
+		},
+	}
+}
+
+func (g *CAdvisorEntityGetter) Name() string {
+	return g.name
+}
+
+func (g *CAdvisorEntityGetter) Category() string {
+	return CAdvisorGetterCategory
+}
+
+func (g *CAdvisorEntityGetter) GetEntityMetric(client xfire.MetricClient) ([]*inter.EntityMetric, error) {
+	result := []*inter.EntityMetric{}
+	containers := make(map[string]*inter.EntityMetric)
+	// the node (instance) of the containers
+	nodes := make(map[string]string)
+
+	//1. the metrics of the containers
+	for _, q := range g.queries {
+		metrics, err := g.getMetrics(client, q.query)
+		if err != nil {
+			err = fmt.Errorf("Failed to get cAdvisor %v metrics: %v", q.name(), err)
+			if q.required {
+				glog.Errorf("%v", err)
+				return result, err
+			}
+			glog.Warningf("%v", err)
+			continue
+		}
+		g.addContainers(metrics, containers, nodes, q)
+	}
+
+	//2. the capacities of the nodes, for the containers without limits
+	nodeCapacities := g.getNodeCapacities(client)
+	for uid, c := range containers {
+		for ctype, capacity := range nodeCapacities[nodes[uid]] {
+			if _, ok := c.Capacities[ctype]; !ok {
+				c.SetCapacity(ctype, capacity)
+			}
+		}
+	}
+
+	//3. the pods of the containers
+	pods := g.buildPods(containers, nodes, nodeCapacities)
+
+	//4. the pod IPs as the stitching label of the pods
+	g.addPodIPs(client, containers, pods)
+
+	glog.V(4).Infof("len(Containers)=%d, len(Pods)=%d", len(containers), len(pods))
+
+	//5. reform map to list
+	for _, v := range containers {
+		result = append(result, v)
+	}
+	for _, v := range pods {
+		result = append(result, v)
+	}
+
+	return result, nil
+}
+
+func (g *CAdvisorEntityGetter) getMetrics(client xfire.MetricClient, exp string) ([]*xfire.BasicMetricData, error) {
+	query := xfire.NewBasicInput()
+	query.SetQuery(exp)
+	mdat, err := client.GetMetrics(query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*xfire.BasicMetricData{}
+	for _, dat := range mdat {
+		metric, ok := dat.(*xfire.BasicMetricData)
+		if !ok {
+			glog.Errorf("Type assertion failed for[%v].", exp)
+			continue
+		}
+		result = append(result, metric)
+	}
+	return result, nil
+}
+
+// addContainers creates the container entities from the metric data
+func (g *CAdvisorEntityGetter) addContainers(metrics []*xfire.BasicMetricData, containers map[string]*inter.EntityMetric,
+	nodes map[string]string, q *instanceQuery) {
+	for _, metric := range metrics {
+		namespace := metric.Labels[cadvisorNamespace]
+		pod := metric.Labels[cadvisorPod]
+		container := metric.Labels[cadvisorContainer]
+		if len(namespace) < 1 || len(pod) < 1 || len(container) < 1 {
+			glog.V(3).Infof("Skip cAdvisor metric %v: no namespace, pod or container", metric.Labels)
+			continue
+		}
+
+		uid := strings.Join([]string{namespace, pod, container}, uidSeparator)
+		entity, ok := containers[uid]
+		if !ok {
+			entity = inter.NewEntityMetric(uid, inter.ContainerEntity)
+			entity.SetLabel(inter.Name, uid)
+			entity.SetLabel(cadvisorNamespace, namespace)
+			entity.SetLabel(cadvisorPod, pod)
+			entity.SetLabel(cadvisorContainer, container)
+			entity.SetLabel(inter.Category, g.Category())
+			containers[uid] = entity
+		}
+		if node, ok := metric.Labels[instanceLabel]; ok {
+			nodes[uid] = node
+		}
+
+		if len(q.attribute) > 0 {
+			entity.SetAttribute(q.attribute, metric.GetValue())
+		} else if q.capacity {
+			entity.SetCapacity(q.ctype, metric.GetValue())
+		} else {
+			entity.SetMetric(q.ctype, metric.GetValue())
+		}
+	}
+}
+
+// getNodeCapacities gets the CPU in millicores and the memory in KB of the nodes
+func (g *CAdvisorEntityGetter) getNodeCapacities(client xfire.MetricClient) map[string]map[proto.CommodityDTO_CommodityType]float64 {
+	result := make(map[string]map[proto.CommodityDTO_CommodityType]float64)
+	queries := map[proto.CommodityDTO_CommodityType]string{
+		inter.VCPUType: fmt.Sprintf("max by (%v) (%v) * 1000", instanceLabel, cadvisor_MACHINE_CPU_CORES),
+		inter.VMemType: fmt.Sprintf("max by (%v) (%v) / 1024", instanceLabel, cadvisor_MACHINE_MEMORY),
+	}
+
+	for ctype, exp := range queries {
+		metrics, err := g.getMetrics(client, exp)
+		if err != nil {
+			glog.Warningf("Failed to get the %v capacities of the nodes: %v", ctype, err)
+			continue
+		}
+
+		for _, metric := range metrics {
+			node := metric.Labels[instanceLabel]
+			if _, ok := result[node]; !ok {
+				result[node] = make(map[proto.CommodityDTO_CommodityType]float64)
+			}
+			result[node][ctype] = metric.GetValue()
+		}
+	}
+	return result
+}
+
+// buildPods sums up the usages and capacities of the containers of the pods;
+// the capacities are capped by the ones of the node, and the throttling is the max of the containers
+func (g *CAdvisorEntityGetter) buildPods(containers map[string]*inter.EntityMetric, nodes map[string]string,
+	nodeCapacities map[string]map[proto.CommodityDTO_CommodityType]float64) map[string]*inter.EntityMetric {
+	pods := make(map[string]*inter.EntityMetric)
+	podNodes := make(map[string]string)
+
+	for uid, c := range containers {
+		namespace, pod := c.Labels[cadvisorNamespace], c.Labels[cadvisorPod]
+		puid := namespace + uidSeparator + pod
+		entity, ok := pods[puid]
+		if !ok {
+			entity = inter.NewEntityMetric(puid, inter.PodEntity)
+			entity.SetLabel(inter.Name, puid)
+			entity.SetLabel(cadvisorNamespace, namespace)
+			entity.SetLabel(cadvisorPod, pod)
+			entity.SetLabel(inter.Category, g.Category())
+			pods[puid] = entity
+		}
+		podNodes[puid] = nodes[uid]
+
+		for ctype, v := range c.Metrics {
+			entity.SetMetric(ctype, entity.Metrics[ctype]+v)
+		}
+		for ctype, v := range c.Capacities {
+			entity.SetCapacity(ctype, entity.Capacities[ctype]+v)
+		}
+		if v, ok := c.Attributes[inter.CPUThrottling]; ok && v >= entity.Attributes[inter.CPUThrottling] {
+			entity.SetAttribute(inter.CPUThrottling, v)
+		}
+	}
+
+	for puid, entity := range pods {
+		for ctype, capacity := range nodeCapacities[podNodes[puid]] {
+			if v, ok := entity.Capacities[ctype]; ok && v > capacity {
+				entity.SetCapacity(ctype, capacity)
+			}
+		}
+	}
+
+	return pods
+}
+
+// addPodIPs sets the pod IPs of kube-state-metrics as the "ip" label of the pods and their containers
+func (g *CAdvisorEntityGetter) addPodIPs(client xfire.MetricClient, containers, pods map[string]*inter.EntityMetric) {
+	exp := fmt.Sprintf("max by (%v,%v,%v) (%v{%v!=\"\"})", cadvisorNamespace, cadvisorPod, ksmPodIP, ksm_POD_INFO, ksmPodIP)
+	metrics, err := g.getMetrics(client, exp)
+	if err != nil {
+		glog.Warningf("Failed to get the pod IPs: %v", err)
+		return
+	}
+
+	ips := make(map[string]string)
+	for _, metric := range metrics {
+		puid := metric.Labels[cadvisorNamespace] + uidSeparator + metric.Labels[cadvisorPod]
+		ips[puid] = metric.Labels[ksmPodIP]
+	}
+
+	for puid, entity := range pods {
+		if ip, ok := ips[puid]; ok {
+			entity.SetLabel(inter.IP, ip)
+		}
+	}
+	// the containers of a pod share its IP, which is not a unique stitching key of the containers
+	for _, entity := range containers {
+		puid := entity.Labels[cadvisorNamespace] + uidSeparator + entity.Labels[cadvisorPod]
+		if ip, ok := ips[puid]; ok {
+			entity.SetLabel(ksmPodIP, ip)
+		}
+	}
+}
+
+// exp = sum by (namespace,pod,container,instance) (metric{container!="",container!="POD"} <filter>)
+func sumByContainer(metric, filter string) string {
+	return fmt.Sprintf("sum by (%v) (%v{%v}%v)", containerGroupBy(), metric, cadvisorContainerSelector, filter)
+}
+
+// the labels to aggregate the metrics by
+func containerGroupBy() string {
+	return strings.Join([]string{cadvisorNamespace, cadvisorPod, cadvisorContainer, instanceLabel}, ",")
+}
+
+// the used CPU in millicores
+func getCAdvisorCPUExp(du string) string {
+	return fmt.Sprintf("1000*sum by (%v) (rate(%v{%v}[%v]))", containerGroupBy(), cadvisor_CPU_USAGE, cadvisorContainerSelector, du)
+}
+
+// the CPU limit in millicores: quota/period
+func getCAdvisorCPULimitExp() string {
+	return fmt.Sprintf("1000*%v / %v", sumByContainer(cadvisor_CPU_QUOTA, " > 0"), sumByContainer(cadvisor_CPU_PERIOD, ""))
+}
+
+// the percentage of the CFS periods throttled
+func getCAdvisorThrottlingExp(du string) string {
+	rate := func(metric string) string {
+		return fmt.Sprintf("sum by (%v) (rate(%v{%v}[%v]))", containerGroupBy(), metric, cadvisorContainerSelector, du)
+	}
+	return fmt.Sprintf("100*%v / %v", rate(cadvisor_CFS_THROTTLED), rate(cadvisor_CFS_PERIODS))
+}
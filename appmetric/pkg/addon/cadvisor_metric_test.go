@@ -0,0 +1,100 @@
+package addon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+func TestCAdvisorEntityGetter_GetEntityMetric(t *testing.T) {
+	// the series of the containers "app", with limits, and "proxy", without limits
+	series := func(app, proxy string) string {
+		result := `{"metric":{"namespace":"shop","pod":"cart-0","container":"app","instance":"node1"},"value":[1530000000,"` + app + `"]}`
+		if len(proxy) > 0 {
+			result += `,{"metric":{"namespace":"shop","pod":"cart-0","container":"proxy","instance":"node1"},"value":[1530000000,"` + proxy + `"]}`
+		}
+		return result
+	}
+
+	responses := []struct {
+		metric string
+		result string
+	}{
+		{cadvisor_CPU_USAGE, series("250", "50")},
+		{cadvisor_MEMORY_WORKING, series("262144", "65536")},
+		{cadvisor_CPU_QUOTA, series("500", "")},
+		{cadvisor_MEMORY_LIMIT, series("524288", "")},
+		{cadvisor_CFS_THROTTLED, series("12.5", "0")},
+		{cadvisor_MACHINE_CPU_CORES, `{"metric":{"instance":"node1"},"value":[1530000000,"4000"]}`},
+		{cadvisor_MACHINE_MEMORY, `{"metric":{"instance":"node1"},"value":[1530000000,"16777216"]}`},
+		{ksm_POD_INFO, `{"metric":{"namespace":"shop","pod":"cart-0","pod_ip":"10.2.4.15"},"value":[1530000000,"1"]}`},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		for _, resp := range responses {
+			if strings.Contains(query, resp.metric) {
+				w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[` + resp.result + `]}}`))
+				return
+			}
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+
+	g, err := NewGetterFactory().CreateEntityGetter(CAdvisorGetterCategory, "cadvisor.container.metric", "3m")
+	if err != nil {
+		t.Fatalf("Failed to create getter: %v", err)
+	}
+
+	result, err := g.GetEntityMetric(client)
+	if err != nil || len(result) != 3 {
+		t.Fatalf("Failed to get entity metrics: %+v, %v", result, err)
+	}
+
+	entities := make(map[string]*inter.EntityMetric)
+	for _, e := range result {
+		entities[e.UID] = e
+		// only the pod has the IP as the stitching label; the containers have it as the pod IP
+		ipLabel := ksmPodIP
+		if e.Type == inter.PodEntity {
+			ipLabel = inter.IP
+		}
+		if e.Labels[ipLabel] != "10.2.4.15" {
+			t.Errorf("Wrong pod IP of %v: %+v", e.UID, e.Labels)
+		}
+		if e.Type == inter.ContainerEntity && e.Labels[inter.IP] != "" {
+			t.Errorf("The container %v should have no IP label: %+v", e.UID, e.Labels)
+		}
+	}
+
+	app := entities["shop/cart-0/app"]
+	if app == nil || app.Type != inter.ContainerEntity || app.Metrics[inter.VCPUType] != 250 || app.Capacities[inter.VCPUType] != 500 ||
+		app.Capacities[inter.VMemType] != 524288 || app.Attributes[inter.CPUThrottling] != 12.5 {
+		t.Errorf("Wrong container app: %+v", app)
+	}
+
+	// the container without limits has the capacities of the node
+	proxy := entities["shop/cart-0/proxy"]
+	if proxy == nil || proxy.Capacities[inter.VCPUType] != 4000 || proxy.Capacities[inter.VMemType] != 16777216 {
+		t.Errorf("Wrong container proxy: %+v", proxy)
+	}
+
+	// the pod sums up the containers, capped by the node
+	pod := entities["shop/cart-0"]
+	if pod == nil || pod.Type != inter.PodEntity || pod.Metrics[inter.VCPUType] != 300 || pod.Metrics[inter.VMemType] != 327680 {
+		t.Errorf("Wrong pod usages: %+v", pod)
+	}
+	if pod.Capacities[inter.VCPUType] != 4000 || pod.Capacities[inter.VMemType] != 16777216 || pod.Attributes[inter.CPUThrottling] != 12.5 {
+		t.Errorf("Wrong pod capacities: %+v, %+v", pod.Capacities, pod.Attributes)
+	}
+}
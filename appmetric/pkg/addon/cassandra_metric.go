@@ -49,7 +49,7 @@ func (r *CassandraEntityGetter) Category() string {
 	return "Cassandra"
 }
 
-func (r *CassandraEntityGetter) GetEntityMetric(client *xfire.RestClient) ([]*inter.EntityMetric, error) {
+func (r *CassandraEntityGetter) GetEntityMetric(client xfire.MetricClient) ([]*inter.EntityMetric, error) {
 	result := []*inter.EntityMetric{}
 	midResult := make(map[string]*inter.EntityMetric)
 
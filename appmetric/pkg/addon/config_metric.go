@@ -0,0 +1,391 @@
+package addon
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/prometheus/common/model"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/util"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+	"io/ioutil"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	defaultRangeStep = "15s"
+	uidSeparator     = "/"
+)
+
+// EntityGetterConf is the content of the getter config file
+type EntityGetterConf struct {
+	Getters []*EntityGetterDef `json:"getters"`
+}
+
+// EntityGetterDef defines an entity metric getter without code:
+// the entities are built from the results of the PromQL queries of its metrics.
+type EntityGetterDef struct {
+	Name       string `json:"name"`
+	Category   string `json:"category"`
+	EntityType string `json:"entityType"`
+
+	// values of the key labels are joined by "/" as the entity UID
+	KeyLabels []string `json:"keyLabels,omitempty"`
+
+	// label of the "ip[:port]" address; the IP will be the UID if KeyLabels is empty
+	IPLabel     string `json:"ipLabel,omitempty"`
+	DefaultPort int    `json:"defaultPort,omitempty"`
+
+	// copy rules of labels: entity label name -> metric label name
+	Labels map[string]string `json:"labels,omitempty"`
+
+	Metrics []*CommodityMetricDef `json:"metrics"`
+
+	// deadline of the getter, e.g., "10s"; the default timeout is used if not set
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// CommodityMetricDef defines the query of one commodity
+type CommodityMetricDef struct {
+	Commodity string `json:"commodity"`
+
+	// PromQL template, for example: rate(redis_commands_processed_total[{{.Duration}}])
+	Query string `json:"query"`
+
+	// if set, a range query over the sample duration is sent,
+	// and each series is reduced by "avg", "max" or "percentile"
+	Reduce     string  `json:"reduce,omitempty"`
+	Percentile float64 `json:"percentile,omitempty"`
+	Step       string  `json:"step,omitempty"`
+}
+
+// the data to execute the query templates
+type queryTemplateData struct {
+	Duration string
+}
+
+// ReadEntityGetterConf reads the getter definitions from a json file;
+// only json is supported, the same as the --config file, as no yaml parser is vendored
+func ReadEntityGetterConf(fname string) (*EntityGetterConf, error) {
+	glog.V(2).Infof("Reading getter config file: %v", fname)
+	content, err := ioutil.ReadFile(fname)
+	if err != nil {
+		glog.Errorf("Failed to read getter config file(%v): %v", fname, err)
+		return nil, err
+	}
+
+	var conf EntityGetterConf
+	if err := json.Unmarshal(content, &conf); err != nil {
+		glog.Errorf("Failed to unmarshal getter config: %v", err)
+		return nil, err
+	}
+
+	for _, def := range conf.Getters {
+		if err := def.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	glog.V(3).Infof("Getter config results: %d getters", len(conf.Getters))
+
+	return &conf, nil
+}
+
+func (d *EntityGetterDef) GetEntityType() (proto.EntityDTO_EntityType, error) {
+	v, ok := proto.EntityDTO_EntityType_value[d.EntityType]
+	if !ok {
+		return proto.EntityDTO_UNKNOWN, fmt.Errorf("Unknown entity type: %v", d.EntityType)
+	}
+	return proto.EntityDTO_EntityType(v), nil
+}
+
+// GetTimeout returns the deadline of the getter, 0 if not set
+func (d *EntityGetterDef) GetTimeout() (time.Duration, error) {
+	if len(d.Timeout) < 1 {
+		return 0, nil
+	}
+	return time.ParseDuration(d.Timeout)
+}
+
+func (d *EntityGetterDef) Validate() error {
+	if len(d.Name) < 1 {
+		return fmt.Errorf("Getter name is empty")
+	}
+
+	if _, err := d.GetEntityType(); err != nil {
+		return fmt.Errorf("Getter %v: %v", d.Name, err)
+	}
+
+	if len(d.KeyLabels) < 1 && len(d.IPLabel) < 1 {
+		return fmt.Errorf("Getter %v: neither keyLabels nor ipLabel is set", d.Name)
+	}
+
+	if len(d.Metrics) < 1 {
+		return fmt.Errorf("Getter %v: no metric is defined", d.Name)
+	}
+
+	if _, err := d.GetTimeout(); err != nil {
+		return fmt.Errorf("Getter %v: invalid timeout: %v", d.Name, err)
+	}
+
+	commodities := make(map[string]bool)
+	for _, m := range d.Metrics {
+		if _, ok := proto.CommodityDTO_CommodityType_value[m.Commodity]; !ok {
+			return fmt.Errorf("Getter %v: unknown commodity type: %v", d.Name, m.Commodity)
+		}
+		if commodities[m.Commodity] {
+			return fmt.Errorf("Getter %v: duplicated commodity: %v", d.Name, m.Commodity)
+		}
+		commodities[m.Commodity] = true
+		if len(strings.TrimSpace(m.Query)) < 1 {
+			return fmt.Errorf("Getter %v: query of %v is empty", d.Name, m.Commodity)
+		}
+		if len(m.Reduce) > 0 {
+			if _, err := xfire.NewReducer(m.Reduce, m.Percentile); err != nil {
+				return fmt.Errorf("Getter %v: %v", d.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ConfigEntityGetter is an EntityMetricGetter built from an EntityGetterDef
+type ConfigEntityGetter struct {
+	def        *EntityGetterDef
+	entityType proto.EntityDTO_EntityType
+	queries    map[proto.CommodityDTO_CommodityType]*configQuery
+}
+
+// ensure ConfigEntityGetter implement the requisite interfaces
+var _ alligator.EntityMetricGetter = &ConfigEntityGetter{}
+
+func NewConfigEntityGetter(def *EntityGetterDef, du string) (*ConfigEntityGetter, error) {
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+
+	etype, _ := def.GetEntityType()
+	g := &ConfigEntityGetter{
+		def:        def,
+		entityType: etype,
+		queries:    make(map[proto.CommodityDTO_CommodityType]*configQuery),
+	}
+
+	for _, m := range def.Metrics {
+		q, err := newConfigQuery(m, du)
+		if err != nil {
+			return nil, fmt.Errorf("Getter %v: %v", def.Name, err)
+		}
+		ctype := proto.CommodityDTO_CommodityType(proto.CommodityDTO_CommodityType_value[m.Commodity])
+		g.queries[ctype] = q
+	}
+
+	return g, nil
+}
+
+func (g *ConfigEntityGetter) Name() string {
+	return g.def.Name
+}
+
+func (g *ConfigEntityGetter) Category() string {
+	return g.def.Category
+}
+
+func (g *ConfigEntityGetter) EntityType() proto.EntityDTO_EntityType {
+	return g.entityType
+}
+
+func (g *ConfigEntityGetter) GetEntityMetric(client xfire.MetricClient) ([]*inter.EntityMetric, error) {
+	result := []*inter.EntityMetric{}
+	midResult := make(map[string]*inter.EntityMetric)
+
+	for ctype, q := range g.queries {
+		var metrics []xfire.MetricData
+		var err error
+		if q.isRange() {
+			metrics, err = client.GetRangeMetrics(q)
+		} else {
+			metrics, err = client.GetMetrics(q)
+		}
+
+		if err != nil {
+			glog.Errorf("Failed to get %v metrics for %v: %v", ctype, g.Name(), err)
+			return result, err
+		}
+		g.addEntity(metrics, midResult, ctype)
+	}
+
+	for _, v := range midResult {
+		result = append(result, v)
+	}
+
+	return result, nil
+}
+
+// addEntity creates entities from the metric data
+func (g *ConfigEntityGetter) addEntity(mdat []xfire.MetricData, result map[string]*inter.EntityMetric, key proto.CommodityDTO_CommodityType) {
+	for _, dat := range mdat {
+		metric, ok := dat.(*xfire.BasicMetricData)
+		if !ok {
+			glog.Errorf("Type assertion failed for[%v].", key)
+			continue
+		}
+
+		uid, labels, err := g.parseLabels(metric.Labels)
+		if err != nil {
+			glog.Errorf("Getter %v: %v", g.Name(), err)
+			continue
+		}
+
+		entity, ok := result[uid]
+		if !ok {
+			entity = inter.NewEntityMetric(uid, g.entityType)
+			for k, v := range labels {
+				entity.SetLabel(k, v)
+			}
+			entity.SetLabel(inter.Category, g.Category())
+			result[uid] = entity
+		}
+
+		entity.SetMetric(key, metric.GetValue())
+	}
+}
+
+// parseLabels generates the entity UID and labels from the metric labels
+func (g *ConfigEntityGetter) parseLabels(mlabels map[string]string) (string, map[string]string, error) {
+	labels := make(map[string]string)
+	uid := ""
+
+	//1. ip and port
+	if len(g.def.IPLabel) > 0 {
+		addr, ok := mlabels[g.def.IPLabel]
+		if !ok {
+			return "", nil, fmt.Errorf("Label %v is not found", g.def.IPLabel)
+		}
+
+		ip, port, err := util.ParseIP(addr, g.def.DefaultPort)
+		if err != nil {
+			return "", nil, fmt.Errorf("Failed to parse IP from addr[%v]: %v", addr, err)
+		}
+		labels[inter.IP] = ip
+		labels[inter.Port] = port
+		uid = ip
+	}
+
+	//2. key labels
+	if len(g.def.KeyLabels) > 0 {
+		keys := []string{}
+		for _, name := range g.def.KeyLabels {
+			v, ok := mlabels[name]
+			if !ok || len(v) < 1 {
+				return "", nil, fmt.Errorf("Key label %v is not found", name)
+			}
+			keys = append(keys, v)
+		}
+		uid = strings.Join(keys, uidSeparator)
+	}
+
+	//3. copy labels
+	for to, from := range g.def.Labels {
+		if v, ok := mlabels[from]; ok {
+			labels[to] = v
+		}
+	}
+
+	return uid, labels, nil
+}
+
+// ------------------ Get and Parse the metrics ---------------
+type configQuery struct {
+	query   string
+	window  time.Duration
+	step    time.Duration
+	reducer *xfire.Reducer
+}
+
+func newConfigQuery(m *CommodityMetricDef, du string) (*configQuery, error) {
+	query, err := executeQueryTemplate(m.Query, du)
+	if err != nil {
+		return nil, err
+	}
+	q := &configQuery{query: query}
+
+	if len(m.Reduce) < 1 {
+		return q, nil
+	}
+
+	//range query
+	if q.reducer, err = xfire.NewReducer(m.Reduce, m.Percentile); err != nil {
+		return nil, err
+	}
+
+	window, err := model.ParseDuration(du)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid sample duration %v: %v", du, err)
+	}
+	q.window = time.Duration(window)
+
+	step := m.Step
+	if len(step) < 1 {
+		step = defaultRangeStep
+	}
+	dstep, err := model.ParseDuration(step)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid step %v: %v", step, err)
+	}
+	q.step = time.Duration(dstep)
+
+	return q, nil
+}
+
+// executeQueryTemplate replaces the placeholders, such as {{.Duration}}, in the PromQL template
+func executeQueryTemplate(qtemplate, du string) (string, error) {
+	tmp, err := template.New("query").Option("missingkey=error").Parse(qtemplate)
+	if err != nil {
+		return "", fmt.Errorf("Failed to parse query template %v: %v", qtemplate, err)
+	}
+
+	var result bytes.Buffer
+	if err := tmp.Execute(&result, &queryTemplateData{Duration: du}); err != nil {
+		return "", fmt.Errorf("Failed to execute query template %v: %v", qtemplate, err)
+	}
+
+	glog.V(3).Infof("query: %v", result.String())
+	return result.String(), nil
+}
+
+func (q *configQuery) isRange() bool {
+	return q.reducer != nil
+}
+
+func (q *configQuery) GetQuery() string {
+	return q.query
+}
+
+func (q *configQuery) GetRange() (time.Time, time.Time, time.Duration) {
+	end := time.Now()
+	return end.Add(-q.window), end, q.step
+}
+
+func (q *configQuery) Parse(m *xfire.RawMetric) (xfire.MetricData, error) {
+	d := xfire.NewBasicMetricData()
+	if err := d.Parse(m); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (q *configQuery) ParseSeries(s *xfire.RawSeries) (xfire.MetricData, error) {
+	d := xfire.NewBasicMetricData()
+	if err := d.ParseSeries(s, q.reducer); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
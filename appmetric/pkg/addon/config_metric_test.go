@@ -0,0 +1,141 @@
+package addon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+func newTestGetterDef() *EntityGetterDef {
+	return &EntityGetterDef{
+		Name:        "redis.config.metric",
+		Category:    "Redis",
+		EntityType:  "APPLICATION",
+		IPLabel:     "addr",
+		DefaultPort: 6379,
+		Labels:      map[string]string{"job": "job"},
+		Metrics: []*CommodityMetricDef{
+			{
+				Commodity: "TRANSACTION",
+				Query:     "rate(redis_commands_processed_total[{{.Duration}}])",
+			},
+		},
+	}
+}
+
+func TestEntityGetterDef_Validate(t *testing.T) {
+	if err := newTestGetterDef().Validate(); err != nil {
+		t.Errorf("Validation failed: %v", err)
+	}
+
+	def := newTestGetterDef()
+	def.EntityType = "APP"
+	if err := def.Validate(); err == nil {
+		t.Errorf("Validation should have failed with entity type: %v", def.EntityType)
+	}
+
+	def = newTestGetterDef()
+	def.IPLabel = ""
+	if err := def.Validate(); err == nil {
+		t.Errorf("Validation should have failed without key labels")
+	}
+
+	def = newTestGetterDef()
+	def.Metrics[0].Commodity = "TPS"
+	if err := def.Validate(); err == nil {
+		t.Errorf("Validation should have failed with commodity: %v", def.Metrics[0].Commodity)
+	}
+
+	def = newTestGetterDef()
+	def.Metrics = append(def.Metrics, &CommodityMetricDef{Commodity: "TRANSACTION", Query: "sum(redis_connected_clients)"})
+	if err := def.Validate(); err == nil {
+		t.Errorf("Validation should have failed with duplicated commodity")
+	}
+}
+
+func TestExecuteQueryTemplate(t *testing.T) {
+	q, err := executeQueryTemplate("rate(redis_commands_processed_total[{{.Duration}}])", "3m")
+	if err != nil {
+		t.Errorf("Failed to execute template: %v", err)
+		return
+	}
+
+	expected := "rate(redis_commands_processed_total[3m])"
+	if q != expected {
+		t.Errorf("Wrong query: %v Vs. %v", q, expected)
+	}
+
+	if _, err := executeQueryTemplate("rate(x[{{.Window}}])", "3m"); err == nil {
+		t.Errorf("Template with unknown placeholder should have failed")
+	}
+}
+
+func TestConfigEntityGetter_GetEntityMetric(t *testing.T) {
+	body := `{"status":"success","data":{"resultType":"vector","result":[
+		{"metric":{"addr":"10.0.2.3:6380","job":"redis"},"value":[1530000000,"12.5"]},
+		{"metric":{"addr":"10.0.3.2","job":"redis"},"value":[1530000000,"3"]},
+		{"metric":{"job":"redis"},"value":[1530000000,"3"]}]}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query().Get("query")
+		if !strings.Contains(q, "[3m]") {
+			t.Errorf("Placeholder is not replaced: %v", q)
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Errorf("Failed to create rest client: %v", err)
+		return
+	}
+
+	getter, err := NewConfigEntityGetter(newTestGetterDef(), "3m")
+	if err != nil {
+		t.Errorf("Failed to create getter: %v", err)
+		return
+	}
+
+	result, err := getter.GetEntityMetric(client)
+	if err != nil {
+		t.Errorf("Failed to get entity metrics: %v", err)
+		return
+	}
+
+	if len(result) != 2 {
+		t.Errorf("Wrong number of entities: %d Vs. 2", len(result))
+	}
+
+	for _, e := range result {
+		if e.Type != inter.AppEntity || e.Labels[inter.IP] != e.UID || e.Labels["job"] != "redis" {
+			t.Errorf("Wrong entity: %+v", e)
+		}
+
+		if e.UID == "10.0.2.3" && (e.Labels[inter.Port] != "6380" || e.Metrics[inter.TpsType] != 12.5) {
+			t.Errorf("Wrong entity: %+v", e)
+		}
+
+		if e.UID == "10.0.3.2" && e.Labels[inter.Port] != "6379" {
+			t.Errorf("Wrong default port: %+v", e)
+		}
+	}
+}
+
+func TestReadEntityGetterConf(t *testing.T) {
+	conf, err := ReadEntityGetterConf("../../scripts/config/getters.json")
+	if err != nil {
+		t.Errorf("Failed to read getter config: %v", err)
+		return
+	}
+
+	for _, def := range conf.Getters {
+		if _, err := NewConfigEntityGetter(def, "3m"); err != nil {
+			t.Errorf("Failed to create getter %v: %v", def.Name, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package addon
+
+import (
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+	"strings"
+)
+
+const (
+	responseCodeLabel = "response_code"
+)
+
+// requestStats accumulates the request rates of an entity by the class of response codes
+type requestStats struct {
+	total float64
+	c4xx  float64
+	c5xx  float64
+}
+
+// errorRates accumulates the request rates of the entities, from the rates grouped by response code
+type errorRates map[string]*requestStats
+
+func (r errorRates) add(uid, code string, rate float64) {
+	stats, ok := r[uid]
+	if !ok {
+		stats = &requestStats{}
+		r[uid] = stats
+	}
+
+	stats.total += rate
+	switch {
+	case strings.HasPrefix(code, "4"):
+		stats.c4xx += rate
+	case strings.HasPrefix(code, "5"):
+		stats.c5xx += rate
+	}
+}
+
+// apply sets the request rate and error rates as the attributes of the entities;
+// the entities without successful requests, e.g., failing with 5xx only, get 0 TPS and latency
+func (r errorRates) apply(entities map[string]*inter.EntityMetric) {
+	for uid, stats := range r {
+		entity, ok := entities[uid]
+		if !ok {
+			continue
+		}
+
+		for _, ctype := range []proto.CommodityDTO_CommodityType{inter.TpsType, inter.LatencyType} {
+			if _, ok := entity.Metrics[ctype]; !ok {
+				entity.SetMetric(ctype, 0)
+			}
+		}
+
+		entity.SetAttribute(inter.RequestRate, stats.total)
+		if stats.total > 0 {
+			entity.SetAttribute(inter.ErrorRate4xx, stats.c4xx/stats.total)
+			entity.SetAttribute(inter.ErrorRate5xx, stats.c5xx/stats.total)
+		} else {
+			entity.SetAttribute(inter.ErrorRate4xx, 0)
+			entity.SetAttribute(inter.ErrorRate5xx, 0)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package addon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+func TestErrorRates_Apply(t *testing.T) {
+	rates := make(errorRates)
+	rates.add("a", "200", 6)
+	rates.add("a", "404", 3)
+	rates.add("a", "503", 1)
+	rates.add("b", "200", 0)
+	rates.add("c", "500", 2)
+	rates.add("d", "503", 5)
+
+	entities := map[string]*inter.EntityMetric{
+		"a": inter.NewEntityMetric("a", inter.AppEntity),
+		"b": inter.NewEntityMetric("b", inter.AppEntity),
+		"d": inter.NewEntityMetric("d", inter.AppEntity),
+	}
+	entities["a"].SetMetric(inter.TpsType, 6)
+	entities["a"].SetMetric(inter.LatencyType, 12)
+	rates.apply(entities)
+
+	a := entities["a"].Attributes
+	if a[inter.RequestRate] != 10 || a[inter.ErrorRate4xx] != 0.3 || a[inter.ErrorRate5xx] != 0.1 {
+		t.Errorf("Wrong attributes of a: %+v", a)
+	}
+
+	b := entities["b"].Attributes
+	if v, ok := b[inter.ErrorRate5xx]; !ok || v != 0 {
+		t.Errorf("Wrong attributes of b: %+v", b)
+	}
+
+	if _, ok := entities["c"]; ok {
+		t.Errorf("Entity c should not be created")
+	}
+
+	// the metrics of the successful requests are kept
+	if m := entities["a"].Metrics; m[inter.TpsType] != 6 || m[inter.LatencyType] != 12 {
+		t.Errorf("Wrong metrics of a: %+v", m)
+	}
+
+	// the entity failing with 5xx only has 0 TPS and latency
+	d := entities["d"]
+	if d.Attributes[inter.ErrorRate5xx] != 1 || len(d.Metrics) != 2 || d.Metrics[inter.TpsType] != 0 || d.Metrics[inter.LatencyType] != 0 {
+		t.Errorf("Wrong entity d: %+v", d)
+	}
+}
+
+func TestIstioV2EntityGetter_ErrorRates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		if !strings.Contains(query, responseCodeLabel) {
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"destination_workload_namespace":"default","destination_canonical_service":"reviews","response_code":"200"},
+			 "value":[1530000000,"3"]},
+			{"metric":{"destination_workload_namespace":"default","destination_canonical_service":"reviews","response_code":"500"},
+			 "value":[1530000000,"1"]}]}}`))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Errorf("Failed to create rest client: %v", err)
+		return
+	}
+
+	// the service only has failed requests, so it is absent from the other queries
+	g := newIstioV2EntityGetter("istio.vapp.metric", "3m", true, DefaultLatencyOption())
+	result, err := g.GetEntityMetric(client)
+	if err != nil || len(result) != 1 {
+		t.Errorf("Failed to get entity metrics: %+v, %v", result, err)
+		return
+	}
+
+	e := result[0]
+	if e.UID != "default/reviews" || e.Type != inter.VAppEntity {
+		t.Errorf("Wrong entity: %+v", e)
+	}
+	if e.Attributes[inter.RequestRate] != 4 || e.Attributes[inter.ErrorRate4xx] != 0 || e.Attributes[inter.ErrorRate5xx] != 0.25 {
+		t.Errorf("Wrong attributes: %+v", e.Attributes)
+	}
+	if _, ok := e.Metrics[inter.TpsType]; !ok {
+		t.Errorf("Missing TPS: %+v", e.Metrics)
+	}
+	if _, ok := e.Metrics[inter.LatencyType]; !ok {
+		t.Errorf("Missing latency: %+v", e.Metrics)
+	}
+}
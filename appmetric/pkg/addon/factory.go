@@ -3,6 +3,7 @@ package addon
 import (
 	"fmt"
 	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
 )
 
 const (
@@ -10,13 +11,67 @@ const (
 	CassandraGetterCategory = "Cassandra"
 	IstioGetterCategory     = "Istio"
 	IstioVAppGetterCategory = "Istio.VApp"
+	MySQLGetterCategory     = "MySQL"
+	PostgresGetterCategory  = "PostgreSQL"
+	MongoDBGetterCategory   = "MongoDB"
+	KafkaGetterCategory     = "Kafka"
+	JVMGetterCategory       = "JVM"
+	NodeGetterCategory      = "Node"
+	CAdvisorGetterCategory  = "cAdvisor"
+	GRPCGetterCategory      = "gRPC"
+	BlackboxGetterCategory  = "Blackbox"
+
+	// Istio telemetry v2 (mixerless)
+	IstioV2GetterCategory     = "IstioV2"
+	IstioV2VAppGetterCategory = "IstioV2.VApp"
+
+	// backend services of the ingress-nginx controller
+	NginxIngressVAppGetterCategory = "NginxIngress.VApp"
 )
 
 type GetterFactory struct {
+	// how the latency is computed by the getters of histogram metrics
+	latency *LatencyOption
+
+	// the entity label matched with the Kafka consumer groups
+	kafkaConsumerLabel string
+
+	// whether the gRPC getter reports the metrics of every method
+	grpcMethodKeys bool
+
+	// the entity type of the targets probed by blackbox_exporter, and the label of the probes identifying the target
+	blackboxEntityType  proto.EntityDTO_EntityType
+	blackboxTargetLabel string
 }
 
 func NewGetterFactory() *GetterFactory {
-	return &GetterFactory{}
+	return &GetterFactory{
+		latency:             DefaultLatencyOption(),
+		kafkaConsumerLabel:  DefaultKafkaConsumerLabel,
+		blackboxEntityType:  DefaultBlackboxEntityType,
+		blackboxTargetLabel: DefaultBlackboxTargetLabel,
+	}
+}
+
+// SetLatencyOption sets how the latency is computed by the getters created afterwards
+func (f *GetterFactory) SetLatencyOption(latency *LatencyOption) {
+	f.latency = latency
+}
+
+// SetKafkaConsumerLabel sets the entity label, whose value is the consumer group, to attach the Kafka consumer lag
+func (f *GetterFactory) SetKafkaConsumerLabel(label string) {
+	f.kafkaConsumerLabel = label
+}
+
+// SetGRPCMethodKeys sets whether the gRPC getter reports TPS and latency of every method as the keyed metrics
+func (f *GetterFactory) SetGRPCMethodKeys(methodKeys bool) {
+	f.grpcMethodKeys = methodKeys
+}
+
+// SetBlackboxTarget sets the entity type of the probed targets, and the label of the probes whose value identifies the target
+func (f *GetterFactory) SetBlackboxTarget(entityType proto.EntityDTO_EntityType, targetLabel string) {
+	f.blackboxEntityType = entityType
+	f.blackboxTargetLabel = targetLabel
 }
 
 func (f *GetterFactory) CreateEntityGetter(category, name, du string) (alligator.EntityMetricGetter, error) {
@@ -25,17 +80,46 @@ func (f *GetterFactory) CreateEntityGetter(category, name, du string) (alligator
 		return NewRedisEntityGetter(name, du), nil
 	case CassandraGetterCategory:
 		return NewCassandraEntityGetter(name, du), nil
+	case MySQLGetterCategory:
+		return NewMySQLEntityGetter(name, du), nil
+	case PostgresGetterCategory:
+		return NewPostgresEntityGetter(name, du), nil
+	case MongoDBGetterCategory:
+		return NewMongoDBEntityGetter(name, du), nil
+	case JVMGetterCategory:
+		return NewJVMEntityGetter(name, du), nil
+	case NodeGetterCategory:
+		return NewNodeEntityGetter(name, du), nil
+	case CAdvisorGetterCategory:
+		return NewCAdvisorEntityGetter(name, du), nil
+	case GRPCGetterCategory:
+		return NewGRPCEntityGetter(name, du, f.latency, f.grpcMethodKeys), nil
+	case BlackboxGetterCategory:
+		return NewBlackboxEntityGetter(name, du, f.blackboxEntityType, f.blackboxTargetLabel)
+	case KafkaGetterCategory:
+		return NewKafkaEntityGetter(name, du, f.kafkaConsumerLabel), nil
 	case IstioGetterCategory:
-		g := newIstioEntityGetter(name, du)
+		g := newIstioEntityGetter(name, du, f.latency)
 		forVapp := false
 		g.SetType(forVapp)
 		return g, nil
 	case IstioVAppGetterCategory:
-		g := newIstioEntityGetter(name, du)
+		g := newIstioEntityGetter(name, du, f.latency)
 		forVapp := true
 		g.SetType(forVapp)
 		return g, nil
+	case IstioV2GetterCategory:
+		return newIstioV2EntityGetter(name, du, false, f.latency), nil
+	case IstioV2VAppGetterCategory:
+		return newIstioV2EntityGetter(name, du, true, f.latency), nil
+	case NginxIngressVAppGetterCategory:
+		return NewNginxIngressEntityGetter(name, du, f.latency), nil
 	}
 
 	return nil, fmt.Errorf("Unknown category: %v", category)
 }
+
+// CreateConfigEntityGetter creates a getter from its definition in the getter config file
+func (f *GetterFactory) CreateConfigEntityGetter(def *EntityGetterDef, du string) (alligator.EntityMetricGetter, error) {
+	return NewConfigEntityGetter(def, du)
+}
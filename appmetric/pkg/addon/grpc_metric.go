@@ -0,0 +1,262 @@
+package addon
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/util"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+	"strings"
+)
+
+// the server metrics of go-grpc-prometheus
+const (
+	grpc_HANDLED_TOTAL          = "grpc_server_handled_total"
+	grpc_HANDLING_SECONDS_SUM   = "grpc_server_handling_seconds_sum"
+	grpc_HANDLING_SECONDS_COUNT = "grpc_server_handling_seconds_count"
+	grpc_HANDLING_SECONDS       = "grpc_server_handling_seconds_bucket"
+
+	grpcService = "grpc_service"
+	grpcMethod  = "grpc_method"
+	grpcCode    = "grpc_code"
+
+	// the handling time of the streams is the lifetime of the streams, so only the unary RPCs are counted for latency
+	grpcUnarySelector = `grpc_type="unary"`
+)
+
+// the HTTP status codes of the gRPC codes, as mapped by grpc-gateway, to compute the error rates
+var grpcHTTPCodes = map[string]string{
+	"OK":                 "200",
+	"Canceled":           "499",
+	"Unknown":            "500",
+	"InvalidArgument":    "400",
+	"DeadlineExceeded":   "504",
+	"NotFound":           "404",
+	"AlreadyExists":      "409",
+	"PermissionDenied":   "403",
+	"ResourceExhausted":  "429",
+	"FailedPrecondition": "400",
+	"Aborted":            "409",
+	"OutOfRange":         "400",
+	"Unimplemented":      "501",
+	"Internal":           "500",
+	"Unavailable":        "503",
+	"DataLoss":           "500",
+	"Unauthenticated":    "401",
+}
+
+// GRPCEntityGetter builds the pods (Application) serving gRPC from the metrics of go-grpc-prometheus,
+// identified by the IP of the scraped instance: TPS of the handled RPCs, the latency if the handling time histogram
+// is enabled, and the error rates by the HTTP status codes mapped from the gRPC codes.
+// If methodKeys is set, TPS and latency of every method are reported as the keyed metrics, keyed by "<service>/<method>".
+type GRPCEntityGetter struct {
+	name       string
+	du         string
+	latency    *LatencyOption
+	methodKeys bool
+}
+
+// ensure GRPCEntityGetter implement the requisite interfaces
+var _ alligator.EntityMetricGetter = &GRPCEntityGetter{}
+
+func NewGRPCEntityGetter(name, du string, latency *LatencyOption, methodKeys bool) *GRPCEntityGetter {
+	return &GRPCEntityGetter{
+		name:       name,
+		du:         du,
+		latency:    latency,
+		methodKeys: methodKeys,
+	}
+}
+
+func (g *GRPCEntityGetter) Name() string {
+	return g.name
+}
+
+func (g *GRPCEntityGetter) Category() string {
+	return GRPCGetterCategory
+}
+
+func (g *GRPCEntityGetter) GetEntityMetric(client xfire.MetricClient) ([]*inter.EntityMetric, error) {
+	result := []*inter.EntityMetric{}
+	midResult := make(map[string]*inter.EntityMetric)
+
+	//1. get TPS data
+	tpsDat, err := g.getMetrics(client, g.getRPSExp(instanceLabel))
+	if err != nil {
+		glog.Errorf("Failed to get gRPC TPS metrics: %v", err)
+		return result, err
+	}
+	for _, metric := range tpsDat {
+		if entity := g.getEntity(metric.Labels, midResult); entity != nil {
+			entity.SetMetric(inter.TpsType, metric.GetValue())
+		}
+	}
+
+	//2. get Latency data, only if the handling time histogram is enabled
+	latencyDat, err := g.getMetrics(client, g.getLatencyExp(g.latency.Quantile, instanceLabel))
+	if err != nil {
+		glog.Warningf("Failed to get gRPC Latency metrics: %v", err)
+	}
+	for _, metric := range latencyDat {
+		if entity := g.getEntity(metric.Labels, midResult); entity != nil {
+			entity.SetMetric(inter.LatencyType, metric.GetValue())
+		}
+	}
+
+	glog.V(4).Infof("len(TPS)=%d, len(Latency)=%d", len(tpsDat), len(latencyDat))
+
+	//3. the latencies of other quantiles as labels
+	for _, q := range g.latency.LabelQuantiles {
+		dat, err := g.getMetrics(client, g.getLatencyExp(q, instanceLabel))
+		if err != nil {
+			glog.Warningf("Failed to get gRPC Latency metrics of quantile %v: %v", q, err)
+			continue
+		}
+		for _, metric := range dat {
+			if entity, ok := midResult[g.getUID(metric.Labels)]; ok {
+				entity.SetLabel(latencyLabel(q), formatLatency(metric.GetValue()))
+			}
+		}
+	}
+
+	//4. the request rate and error rates by the gRPC codes
+	requestDat, err := g.getMetrics(client, g.getRPSExp(instanceLabel+","+grpcCode))
+	if err != nil {
+		glog.Warningf("Failed to get gRPC request metrics by code: %v", err)
+	} else {
+		g.addErrorRates(requestDat, midResult)
+	}
+
+	//5. TPS and latency by method
+	if g.methodKeys {
+		g.addMethodMetrics(client, midResult)
+	}
+
+	//6. reform map to list
+	for _, v := range midResult {
+		result = append(result, v)
+	}
+
+	return result, nil
+}
+
+func (g *GRPCEntityGetter) getMetrics(client xfire.MetricClient, exp string) ([]*xfire.BasicMetricData, error) {
+	query := xfire.NewBasicInput()
+	query.SetQuery(exp)
+	mdat, err := client.GetMetrics(query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*xfire.BasicMetricData{}
+	for _, dat := range mdat {
+		metric, ok := dat.(*xfire.BasicMetricData)
+		if !ok {
+			glog.Errorf("Type assertion failed for[%v].", exp)
+			continue
+		}
+		result = append(result, metric)
+	}
+	return result, nil
+}
+
+// getUID returns the IP of the instance, or empty if the instance is invalid
+func (g *GRPCEntityGetter) getUID(mlabels map[string]string) string {
+	addr, ok := mlabels[instanceLabel]
+	if !ok {
+		glog.V(3).Infof("Skip gRPC metric %v: label %v is not found", mlabels, instanceLabel)
+		return ""
+	}
+
+	ip, _, err := util.ParseIP(addr, 0)
+	if err != nil {
+		glog.V(3).Infof("Skip gRPC metric %v: failed to parse IP from addr[%v]: %v", mlabels, addr, err)
+		return ""
+	}
+	return ip
+}
+
+// getEntity returns the entity of the instance, which is created if not exists
+func (g *GRPCEntityGetter) getEntity(mlabels map[string]string, result map[string]*inter.EntityMetric) *inter.EntityMetric {
+	uid := g.getUID(mlabels)
+	if len(uid) < 1 {
+		return nil
+	}
+
+	if entity, ok := result[uid]; ok {
+		return entity
+	}
+
+	entity := inter.NewEntityMetric(uid, inter.AppEntity)
+	entity.SetLabel(inter.IP, uid)
+	entity.SetLabel(inter.Category, g.Category())
+	result[uid] = entity
+	return entity
+}
+
+// addErrorRates sets the request rate and error rates of the entities, from the request rates by gRPC code
+func (g *GRPCEntityGetter) addErrorRates(metrics []*xfire.BasicMetricData, result map[string]*inter.EntityMetric) {
+	rates := make(errorRates)
+	for _, metric := range metrics {
+		entity := g.getEntity(metric.Labels, result)
+		if entity == nil {
+			continue
+		}
+
+		code, ok := grpcHTTPCodes[metric.Labels[grpcCode]]
+		if !ok {
+			code = grpcHTTPCodes["Unknown"]
+		}
+		rates.add(entity.UID, code, metric.GetValue())
+	}
+	rates.apply(result)
+}
+
+// addMethodMetrics sets TPS and latency of every method as the keyed metrics of the existing entities
+func (g *GRPCEntityGetter) addMethodMetrics(client xfire.MetricClient, result map[string]*inter.EntityMetric) {
+	by := strings.Join([]string{instanceLabel, grpcService, grpcMethod}, ",")
+	queries := map[proto.CommodityDTO_CommodityType]string{
+		inter.TpsType:     g.getRPSExp(by),
+		inter.LatencyType: g.getLatencyExp(g.latency.Quantile, by),
+	}
+
+	for ctype, exp := range queries {
+		metrics, err := g.getMetrics(client, exp)
+		if err != nil {
+			glog.Warningf("Failed to get gRPC %v metrics by method: %v", ctype, err)
+			continue
+		}
+
+		for _, metric := range metrics {
+			entity, ok := result[g.getUID(metric.Labels)]
+			if !ok {
+				continue
+			}
+
+			service, method := metric.Labels[grpcService], metric.Labels[grpcMethod]
+			if len(service) < 1 || len(method) < 1 {
+				continue
+			}
+			entity.SetKeyedMetric(service+uidSeparator+method, ctype, metric.GetValue())
+		}
+	}
+}
+
+// exp = sum by (instance) (rate(grpc_server_handled_total[3m]))
+func (g *GRPCEntityGetter) getRPSExp(by string) string {
+	return fmt.Sprintf("sum by (%v) (rate(%v[%v]))", by, grpc_HANDLED_TOTAL, g.du)
+}
+
+// the latency of the unary RPCs in milliseconds: the quantile of the histogram, or the mean if quantile is 0;
+// the handling time of go-grpc-prometheus is in seconds
+func (g *GRPCEntityGetter) getLatencyExp(quantile float64, by string) string {
+	if quantile > 0 {
+		return "1000*" + histogramQuantileExp(quantile, grpc_HANDLING_SECONDS, grpcUnarySelector, by, g.du)
+	}
+
+	return fmt.Sprintf("1000*sum by (%v) (rate(%v{%v}[%v])) / sum by (%v) (rate(%v{%v}[%v]))",
+		by, grpc_HANDLING_SECONDS_SUM, grpcUnarySelector, g.du,
+		by, grpc_HANDLING_SECONDS_COUNT, grpcUnarySelector, g.du)
+}
@@ -0,0 +1,79 @@
+package addon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+func TestGRPCEntityGetter_GetEntityMetric(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		var result string
+		switch {
+		case strings.Contains(query, grpcCode):
+			result = `{"metric":{"instance":"10.2.6.3:9092","grpc_code":"OK"},"value":[1530000000,"18"]},
+				{"metric":{"instance":"10.2.6.3:9092","grpc_code":"Unavailable"},"value":[1530000000,"1.5"]},
+				{"metric":{"instance":"10.2.6.3:9092","grpc_code":"NotFound"},"value":[1530000000,"0.5"]}`
+		case strings.Contains(query, grpcMethod) && strings.Contains(query, grpc_HANDLING_SECONDS_SUM):
+			result = `{"metric":{"instance":"10.2.6.3:9092","grpc_service":"cart.Cart","grpc_method":"AddItem"},"value":[1530000000,"18"]},
+				{"metric":{"instance":"10.2.6.3:9092","grpc_service":"cart.Cart","grpc_method":"GetCart"},"value":[1530000000,"28"]}`
+		case strings.Contains(query, grpcMethod):
+			result = `{"metric":{"instance":"10.2.6.3:9092","grpc_service":"cart.Cart","grpc_method":"AddItem"},"value":[1530000000,"12"]},
+				{"metric":{"instance":"10.2.6.3:9092","grpc_service":"cart.Cart","grpc_method":"GetCart"},"value":[1530000000,"8"]}`
+		case strings.Contains(query, grpc_HANDLING_SECONDS_SUM):
+			result = `{"metric":{"instance":"10.2.6.3:9092"},"value":[1530000000,"20.5"]}`
+		default:
+			result = `{"metric":{"instance":"10.2.6.3:9092"},"value":[1530000000,"20"]}`
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[` + result + `]}}`))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+
+	for _, methodKeys := range []bool{false, true} {
+		factory := NewGetterFactory()
+		factory.SetGRPCMethodKeys(methodKeys)
+		g, err := factory.CreateEntityGetter(GRPCGetterCategory, "grpc.app.metric", "3m")
+		if err != nil {
+			t.Fatalf("Failed to create getter: %v", err)
+		}
+
+		result, err := g.GetEntityMetric(client)
+		if err != nil || len(result) != 1 {
+			t.Fatalf("Failed to get entity metrics: %+v, %v", result, err)
+		}
+
+		e := result[0]
+		if e.UID != "10.2.6.3" || e.Type != inter.AppEntity || e.Labels[inter.Category] != GRPCGetterCategory {
+			t.Errorf("Wrong entity: %+v", e)
+		}
+		if e.Metrics[inter.TpsType] != 20 || e.Metrics[inter.LatencyType] != 20.5 {
+			t.Errorf("Wrong metrics: %+v", e.Metrics)
+		}
+		if e.Attributes[inter.RequestRate] != 20 || e.Attributes[inter.ErrorRate5xx] != 0.075 || e.Attributes[inter.ErrorRate4xx] != 0.025 {
+			t.Errorf("Wrong error rates: %+v", e.Attributes)
+		}
+
+		if !methodKeys {
+			if len(e.KeyedMetrics) != 0 {
+				t.Errorf("Unexpected keyed metrics: %+v", e.KeyedMetrics)
+			}
+			continue
+		}
+		if m := e.KeyedMetrics["cart.Cart/AddItem"]; m[inter.TpsType] != 12 || m[inter.LatencyType] != 18 {
+			t.Errorf("Wrong metrics of method AddItem: %+v", e.KeyedMetrics)
+		}
+		if m := e.KeyedMetrics["cart.Cart/GetCart"]; m[inter.TpsType] != 8 || m[inter.LatencyType] != 28 {
+			t.Errorf("Wrong metrics of method GetCart: %+v", e.KeyedMetrics)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package addon
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/util"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+const (
+	instanceLabel = "instance"
+)
+
+// instanceQuery is the query of a commodity of the entities
+type instanceQuery struct {
+	ctype proto.CommodityDTO_CommodityType
+	query string
+	// the value is the capacity of the commodity, instead of its usage
+	capacity bool
+	// the getter fails if the query fails; the other queries only log the failures
+	required bool
+	// if set, the value is reported as the attribute, instead of a commodity
+	attribute string
+}
+
+// name is the attribute, or the commodity type, of the query
+func (q *instanceQuery) name() string {
+	if len(q.attribute) > 0 {
+		return q.attribute
+	}
+	return q.ctype.String()
+}
+
+// instanceEntityGetter builds the entities from the metrics of an exporter,
+// such as mysqld_exporter, with one entity per scraped exporter instance:
+// the entity is identified by the IP of the "instance" label, the same as the Cassandra getter.
+type instanceEntityGetter struct {
+	name        string
+	category    string
+	entityType  proto.EntityDTO_EntityType
+	defaultPort int
+	queries     []*instanceQuery
+	// the prefix of the UID, to tell the entity from the ones of other types with the same IP, e.g., the VM of an application
+	uidPrefix string
+}
+
+func (g *instanceEntityGetter) Name() string {
+	return g.name
+}
+
+func (g *instanceEntityGetter) Category() string {
+	return g.category
+}
+
+func (g *instanceEntityGetter) GetEntityMetric(client xfire.MetricClient) ([]*inter.EntityMetric, error) {
+	result := []*inter.EntityMetric{}
+	midResult := make(map[string]*inter.EntityMetric)
+
+	for _, q := range g.queries {
+		query := xfire.NewBasicInput()
+		query.SetQuery(q.query)
+		metrics, err := client.GetMetrics(query)
+		if err != nil {
+			err = fmt.Errorf("Failed to get %v %v metrics: %v", g.category, q.name(), err)
+			if q.required {
+				glog.Errorf("%v", err)
+				return result, err
+			}
+			glog.Warningf("%v", err)
+			continue
+		}
+		g.addEntity(metrics, midResult, q)
+	}
+
+	for _, v := range midResult {
+		result = append(result, v)
+	}
+
+	return result, nil
+}
+
+// addEntity creates entities from the metric data
+func (g *instanceEntityGetter) addEntity(mdat []xfire.MetricData, result map[string]*inter.EntityMetric, q *instanceQuery) {
+	for _, dat := range mdat {
+		metric, ok := dat.(*xfire.BasicMetricData)
+		if !ok {
+			glog.Errorf("Type assertion failed for[%v].", q.name())
+			continue
+		}
+
+		//1. get IP
+		addr, ok := metric.Labels[instanceLabel]
+		if !ok {
+			glog.Errorf("Label %v is not found", instanceLabel)
+			continue
+		}
+
+		ip, port, err := util.ParseIP(addr, g.defaultPort)
+		if err != nil {
+			glog.Errorf("Failed to parse IP from addr[%v]: %v", addr, err)
+			continue
+		}
+
+		//2. add entity metrics
+		uid := g.uidPrefix + ip
+		entity, ok := result[uid]
+		if !ok {
+			entity = inter.NewEntityMetric(uid, g.entityType)
+			entity.SetLabel(inter.IP, ip)
+			entity.SetLabel(inter.Port, port)
+			entity.SetLabel(inter.Category, g.Category())
+			result[uid] = entity
+		}
+
+		if len(q.attribute) > 0 {
+			entity.SetAttribute(q.attribute, metric.GetValue())
+		} else if q.capacity {
+			entity.SetCapacity(q.ctype, metric.GetValue())
+		} else {
+			entity.SetMetric(q.ctype, metric.GetValue())
+		}
+	}
+}
+
+// exp = sum by (instance) (metric)
+func sumByInstance(metric string) string {
+	return fmt.Sprintf("sum by (%v) (%v)", instanceLabel, metric)
+}
+
+// exp = sum by (instance) (rate(metric[3m]))
+func sumRateByInstance(metric, du string) string {
+	return fmt.Sprintf("sum by (%v) (rate(%v[%v]))", instanceLabel, metric, du)
+}
+
+// exp = (first) or (second): the result of the first query, or the second one for the instances not in the first
+func eitherExp(first, second string) string {
+	return fmt.Sprintf("(%v) or (%v)", first, second)
+}
@@ -0,0 +1,62 @@
+package addon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+// metricValue is the value returned for the queries containing the metric
+type metricValue struct {
+	metric string
+	value  string
+}
+
+// getInstanceEntity runs the getter against a mocked prometheus server, which returns one series of the instance
+// for every query; the value is the one of the first metric contained by the query.
+func getInstanceEntity(t *testing.T, category, instance string, values []metricValue) *inter.EntityMetric {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		for _, v := range values {
+			if strings.Contains(query, v.metric) {
+				w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+					{"metric":{"instance":"` + instance + `"},"value":[1530000000,"` + v.value + `"]}]}}`))
+				return
+			}
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+
+	g, err := NewGetterFactory().CreateEntityGetter(category, "test.app.metric", "3m")
+	if err != nil {
+		t.Fatalf("Failed to create %v getter: %v", category, err)
+	}
+
+	result, err := g.GetEntityMetric(client)
+	if err != nil || len(result) != 1 {
+		t.Fatalf("Failed to get entity metrics: %+v, %v", result, err)
+	}
+
+	e := result[0]
+	if e.Labels[inter.Category] != category {
+		t.Errorf("Wrong category: %v", e.Labels[inter.Category])
+	}
+	return e
+}
+
+func TestSumRateByInstance(t *testing.T) {
+	exp := sumRateByInstance(mysql_QUESTIONS, "3m")
+	if exp != "sum by (instance) (rate(mysql_global_status_questions[3m]))" {
+		t.Errorf("Wrong query: %v", exp)
+	}
+}
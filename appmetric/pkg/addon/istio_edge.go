@@ -0,0 +1,157 @@
+package addon
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+	"strings"
+)
+
+// istioEdgeDef defines how the dependencies between the services are built from the standard Istio metrics:
+// the caller and callee of the requests are given by the source and destination labels of istio_requests_total.
+type istioEdgeDef struct {
+	callerNamespace string
+	// the first known label is used as the caller service
+	callerServices  []string
+	calleeNamespace string
+	calleeService   string
+
+	durationSum   string
+	durationCount string
+	// converts the duration to milliseconds, e.g., "1000*" for seconds
+	durationScale string
+}
+
+// the standard metrics of the Mixer (Istio telemetry v1), with the duration in seconds
+var istioMixerEdgeDef = &istioEdgeDef{
+	callerNamespace: "source_workload_namespace",
+	callerServices:  []string{"source_app", "source_workload"},
+	calleeNamespace: "destination_service_namespace",
+	calleeService:   "destination_service_name",
+	durationSum:     "istio_request_duration_seconds_sum",
+	durationCount:   "istio_request_duration_seconds_count",
+	durationScale:   "1000*",
+}
+
+// the standard metrics of Istio telemetry v2, with the duration in milliseconds
+var istioV2EdgeDef = &istioEdgeDef{
+	callerNamespace: "source_workload_namespace",
+	callerServices:  []string{"source_canonical_service", "source_app", "source_workload"},
+	calleeNamespace: istioDstNamespace,
+	calleeService:   istioDstService,
+	durationSum:     istio_REQUEST_DURATION_SUM,
+	durationCount:   istio_REQUEST_DURATION_COUNT,
+}
+
+// getEdges gets the caller->callee edges between the services (VirtualApplications), with the TPS and latency of the calls;
+// the UID of a service is "<namespace>/<service>", the same as the one of the entities.
+func (d *istioEdgeDef) getEdges(client xfire.MetricClient, du string) ([]*inter.Edge, error) {
+	result := []*inter.Edge{}
+	edges := make(map[string]*inter.Edge)
+
+	//1. TPS
+	query := xfire.NewBasicInput()
+	query.SetQuery(d.getRPSExp(du))
+	tpsDat, err := client.GetMetrics(query)
+	if err != nil {
+		glog.Errorf("Failed to get Istio TPS metrics of the edges: %v", err)
+		return result, err
+	}
+	d.addEdge(tpsDat, edges, inter.TpsType)
+
+	//2. latency
+	query = xfire.NewBasicInput()
+	query.SetQuery(d.getLatencyExp(du))
+	latencyDat, err := client.GetMetrics(query)
+	if err != nil {
+		glog.Warningf("Failed to get Istio Latency metrics of the edges: %v", err)
+	} else {
+		d.addEdge(latencyDat, edges, inter.LatencyType)
+	}
+
+	for _, e := range edges {
+		result = append(result, e)
+	}
+	glog.V(4).Infof("len(edges)=%d", len(result))
+
+	return result, nil
+}
+
+func (d *istioEdgeDef) addEdge(mdat []xfire.MetricData, edges map[string]*inter.Edge, key proto.CommodityDTO_CommodityType) {
+	for _, dat := range mdat {
+		metric, ok := dat.(*xfire.BasicMetricData)
+		if !ok {
+			glog.Errorf("Type assertion failed for[%v].", key)
+			continue
+		}
+
+		caller, callee, err := d.parseLabels(metric.Labels)
+		if err != nil {
+			glog.V(3).Infof("Skip Istio edge %v: %v", metric.Labels, err)
+			continue
+		}
+
+		edge := inter.NewEdge(caller, inter.VAppEntity, callee, inter.VAppEntity)
+		if e, ok := edges[edge.Key()]; ok {
+			edge = e
+		} else {
+			edges[edge.Key()] = edge
+		}
+		edge.SetMetric(key, metric.GetValue())
+	}
+}
+
+// parseLabels returns the UIDs of the caller and callee services
+func (d *istioEdgeDef) parseLabels(mlabels map[string]string) (string, string, error) {
+	callerNamespace := mlabels[d.callerNamespace]
+	if !isKnownLabel(callerNamespace) {
+		return "", "", fmt.Errorf("unknown source namespace")
+	}
+
+	caller := ""
+	for _, name := range d.callerServices {
+		if v := mlabels[name]; isKnownLabel(v) {
+			caller = v
+			break
+		}
+	}
+	if len(caller) < 1 {
+		return "", "", fmt.Errorf("unknown source service")
+	}
+
+	calleeNamespace := mlabels[d.calleeNamespace]
+	callee := mlabels[d.calleeService]
+	if !isKnownLabel(calleeNamespace) || !isKnownLabel(callee) {
+		return "", "", fmt.Errorf("unknown destination service")
+	}
+
+	return fmt.Sprintf("%s/%s", callerNamespace, caller), fmt.Sprintf("%s/%s", calleeNamespace, callee), nil
+}
+
+// the labels to aggregate the metrics by
+func (d *istioEdgeDef) groupBy() string {
+	labels := append([]string{d.callerNamespace}, d.callerServices...)
+	labels = append(labels, d.calleeNamespace, d.calleeService)
+	return strings.Join(labels, ",")
+}
+
+// exp = sum by (...) (rate(istio_requests_total{reporter="destination"}[3m]))
+func (d *istioEdgeDef) getRPSExp(du string) string {
+	return fmt.Sprintf("sum by (%v) (rate(%v{%v}[%v]))",
+		d.groupBy(), istio_REQUESTS_TOTAL, istioReporterSelector, du)
+}
+
+// the mean latency in milliseconds
+func (d *istioEdgeDef) getLatencyExp(du string) string {
+	by := d.groupBy()
+	return fmt.Sprintf("%vsum by (%v) (rate(%v{%v}[%v])) / sum by (%v) (rate(%v{%v}[%v]))",
+		d.durationScale,
+		by, d.durationSum, istioReporterSelector, du,
+		by, d.durationCount, istioReporterSelector, du)
+}
+
+func isKnownLabel(v string) bool {
+	return len(v) > 0 && v != "unknown"
+}
@@ -0,0 +1,74 @@
+package addon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+func TestIstioEdgeDef_ParseLabels(t *testing.T) {
+	labels := map[string]string{
+		"source_workload_namespace":     "default",
+		"source_app":                    "unknown",
+		"source_workload":               "productpage-v1",
+		"destination_service_namespace": "default",
+		"destination_service_name":      "reviews",
+	}
+
+	caller, callee, err := istioMixerEdgeDef.parseLabels(labels)
+	if err != nil || caller != "default/productpage-v1" || callee != "default/reviews" {
+		t.Errorf("Failed to parse edge labels: %v, %v, %v", caller, callee, err)
+	}
+
+	labels["source_workload"] = "unknown"
+	if _, _, err := istioMixerEdgeDef.parseLabels(labels); err == nil {
+		t.Errorf("Unknown source should be skipped")
+	}
+}
+
+func TestIstioV2EntityGetter_GetEdges(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := "12"
+		if strings.Contains(r.URL.Query().Get("query"), istio_REQUEST_DURATION_SUM) {
+			value = "35.5"
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"source_workload_namespace":"default","source_canonical_service":"productpage",
+				"destination_workload_namespace":"default","destination_canonical_service":"reviews"},
+			 "value":[1530000000,"` + value + `"]},
+			{"metric":{"source_workload_namespace":"unknown",
+				"destination_workload_namespace":"default","destination_canonical_service":"reviews"},
+			 "value":[1530000000,"` + value + `"]}]}}`))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Errorf("Failed to create rest client: %v", err)
+		return
+	}
+
+	pod := newIstioV2EntityGetter("istio.app.metric", "3m", false, DefaultLatencyOption())
+	if edges, err := pod.GetEdges(client); err != nil || len(edges) != 0 {
+		t.Errorf("Expected no edges of the pods: %+v, %v", edges, err)
+	}
+
+	svc := newIstioV2EntityGetter("istio.vapp.metric", "3m", true, DefaultLatencyOption())
+	edges, err := svc.GetEdges(client)
+	if err != nil || len(edges) != 1 {
+		t.Errorf("Failed to get edges: %+v, %v", edges, err)
+		return
+	}
+
+	e := edges[0]
+	if e.Caller != "default/productpage" || e.Callee != "default/reviews" || e.CalleeType != inter.VAppEntity {
+		t.Errorf("Wrong edge: %+v", e)
+	}
+	if e.Metrics[inter.TpsType] != 12 || e.Metrics[inter.LatencyType] != 35.5 {
+		t.Errorf("Wrong edge metrics: %+v", e.Metrics)
+	}
+}
@@ -21,6 +21,11 @@ const (
 	turbo_POD_LATENCY_COUNT = "istio_turbo_pod_latency_time_ms_count"
 	turbo_POD_REQUEST_COUNT = "istio_turbo_pod_request_count"
 
+	// the latency histograms, and the labels to aggregate the buckets by
+	turbo_SVC_LATENCY_BUCKET = "istio_turbo_service_latency_time_ms_bucket"
+	turbo_POD_LATENCY_BUCKET = "istio_turbo_pod_latency_time_ms_bucket"
+	turbo_LATENCY_BY         = "destination_uid,destination_ip"
+
 	//turboMetricDuration = "3m"
 
 	k8sPrefix    = "kubernetes://"
@@ -31,24 +36,31 @@ const (
 	svcTPS     = 2
 	svcLatency = 3
 
+	// request rates of all the response codes
+	podRequests = 4
+	svcRequests = 5
+
 	podType = 1
 	svcType = 2
 )
 
 type IstioEntityGetter struct {
-	name  string
-	query *istioQuery
-	etype int //Pod(Application), or Service
+	name    string
+	query   *istioQuery
+	etype   int //Pod(Application), or Service
+	latency *LatencyOption
 }
 
 // ensure IstioEntityGetter implement the requisite interfaces
 var _ alligator.EntityMetricGetter = &IstioEntityGetter{}
+var _ alligator.EdgeGetter = &IstioEntityGetter{}
 
-func newIstioEntityGetter(name, du string) *IstioEntityGetter {
+func newIstioEntityGetter(name, du string, latency *LatencyOption) *IstioEntityGetter {
 	return &IstioEntityGetter{
-		name:  name,
-		etype: podType,
-		query: newIstioQuery(du),
+		name:    name,
+		etype:   podType,
+		query:   newIstioQuery(du, latency.Quantile),
+		latency: latency,
 	}
 }
 
@@ -72,26 +84,28 @@ func (istio *IstioEntityGetter) Category() string {
 	return "Istio.VApp"
 }
 
-func (istio *IstioEntityGetter) GetEntityMetric(client *xfire.RestClient) ([]*inter.EntityMetric, error) {
+func (istio *IstioEntityGetter) GetEntityMetric(client xfire.MetricClient) ([]*inter.EntityMetric, error) {
 	result := []*inter.EntityMetric{}
+	// the getter may run against several sources concurrently
+	query := *istio.query
 
 	if istio.etype == podType {
-		istio.query.SetQueryType(podTPS)
+		query.SetQueryType(podTPS)
 	} else {
-		istio.query.SetQueryType(svcTPS)
+		query.SetQueryType(svcTPS)
 	}
-	tpsDat, err := client.GetMetrics(istio.query)
+	tpsDat, err := client.GetMetrics(&query)
 	if err != nil {
 		glog.Errorf("Failed to get Pod Transaction metrics: %v", err)
 		return result, err
 	}
 
 	if istio.etype == podType {
-		istio.query.SetQueryType(podLatency)
+		query.SetQueryType(podLatency)
 	} else {
-		istio.query.SetQueryType(svcLatency)
+		query.SetQueryType(svcLatency)
 	}
-	latencyDat, err := client.GetMetrics(istio.query)
+	latencyDat, err := client.GetMetrics(&query)
 	if err != nil {
 		glog.Errorf("Failed to get pod Latency metrics: %v", err)
 		return result, err
@@ -101,9 +115,102 @@ func (istio *IstioEntityGetter) GetEntityMetric(client *xfire.RestClient) ([]*in
 
 	result = istio.mergeTPSandLatency(tpsDat, latencyDat)
 
+	// the latencies of other quantiles as labels
+	if len(istio.latency.LabelQuantiles) > 0 {
+		istio.addLatencyLabels(client, result, query.GetQueryType())
+	}
+
+	// the request rate and error rates of all the response codes
+	if istio.etype == podType {
+		query.SetQueryType(podRequests)
+	} else {
+		query.SetQueryType(svcRequests)
+	}
+	requestDat, err := client.GetMetrics(&query)
+	if err != nil {
+		glog.Warningf("Failed to get request metrics of all response codes: %v", err)
+	} else {
+		result = istio.addErrorRates(result, requestDat)
+	}
+
 	return result, nil
 }
 
+// GetEdges gets the dependencies between the services from the standard Mixer metrics;
+// no edges for the pods, as the standard metrics don't identify the calling pods.
+func (istio *IstioEntityGetter) GetEdges(client xfire.MetricClient) ([]*inter.Edge, error) {
+	if istio.etype != svcType {
+		return []*inter.Edge{}, nil
+	}
+
+	return istioMixerEdgeDef.getEdges(client, istio.query.du)
+}
+
+// addErrorRates sets the request rate and error rates of the entities;
+// the entities without successful requests are added.
+func (istio *IstioEntityGetter) addErrorRates(entities []*inter.EntityMetric, requestDat []xfire.MetricData) []*inter.EntityMetric {
+	etype := inter.AppEntity
+	if istio.etype == svcType {
+		etype = inter.VAppEntity
+	}
+
+	midresult := make(map[string]*inter.EntityMetric)
+	for _, entity := range entities {
+		midresult[entity.UID] = entity
+	}
+
+	rates := make(errorRates)
+	for _, dat := range requestDat {
+		requests, ok := dat.(*istioMetricData)
+		if !ok {
+			glog.Errorf("Type assertion failed for requests: not an IstioMetricData")
+			continue
+		}
+
+		if _, exist := midresult[requests.uuid]; !exist {
+			glog.V(3).Infof("Entity without successful requests: %+v", requests)
+			entity := inter.NewEntityMetric(requests.uuid, etype)
+			istio.assignMetric(entity, requests)
+			midresult[entity.UID] = entity
+			entities = append(entities, entity)
+		}
+		rates.add(requests.uuid, requests.code, requests.GetValue())
+	}
+	rates.apply(midresult)
+
+	return entities
+}
+
+// addLatencyLabels sets the latencies of the label quantiles as labels of the entities
+func (istio *IstioEntityGetter) addLatencyLabels(client xfire.MetricClient, entities []*inter.EntityMetric, qtype int) {
+	midresult := make(map[string]*inter.EntityMetric)
+	for _, entity := range entities {
+		midresult[entity.UID] = entity
+	}
+
+	for _, quantile := range istio.latency.LabelQuantiles {
+		query := newIstioQuery(istio.query.du, quantile)
+		query.SetQueryType(qtype)
+		dat, err := client.GetMetrics(query)
+		if err != nil {
+			glog.Warningf("Failed to get Latency metrics of quantile %v: %v", quantile, err)
+			continue
+		}
+
+		for _, d := range dat {
+			latency, ok := d.(*istioMetricData)
+			if !ok {
+				glog.Errorf("Type assertion failed for Latency: not an IstioMetricData")
+				continue
+			}
+
+			if entity, exist := midresult[latency.uuid]; exist {
+				entity.SetLabel(latencyLabel(quantile), formatLatency(latency.GetValue()))
+			}
+		}
+	}
+}
+
 func (istio *IstioEntityGetter) assignMetric(entity *inter.EntityMetric, metric *istioMetricData) {
 	for k, v := range metric.Labels {
 		entity.SetLabel(k, v)
@@ -172,6 +279,9 @@ type istioQuery struct {
 	qtype    int
 	du       string
 	queryMap map[int]string
+
+	// the quantile of latency histogram; 0 for the mean latency
+	quantile float64
 }
 
 // IstioMetricData : hold the result of Istio-Prometheus data
@@ -179,15 +289,17 @@ type istioMetricData struct {
 	Labels map[string]string `json:"labels"`
 	Value  float64           `json:"value"`
 	uuid   string
-	dtype  int //0,1,2,3 same as qtype
+	dtype  int //0,1,2,3,4,5 same as qtype
+	code   string
 }
 
 // NewIstioQuery : create a new IstioQuery
-func newIstioQuery(du string) *istioQuery {
+func newIstioQuery(du string, quantile float64) *istioQuery {
 	q := &istioQuery{
 		qtype:    0,
 		du:       du,
 		queryMap: make(map[int]string),
+		quantile: quantile,
 	}
 
 	isPod := true
@@ -196,6 +308,8 @@ func newIstioQuery(du string) *istioQuery {
 	isPod = false
 	q.queryMap[2] = q.getRPSExp(isPod)
 	q.queryMap[3] = q.getLatencyExp(isPod)
+	q.queryMap[podRequests] = q.getRequestsExp(true)
+	q.queryMap[svcRequests] = q.getRequestsExp(false)
 
 	return q
 }
@@ -251,15 +365,22 @@ func (q *istioQuery) String() string {
 func (q *istioQuery) getLatencyExp(pod bool) string {
 	name_sum := ""
 	name_count := ""
+	name_bucket := ""
 	if pod {
 		name_sum = turbo_POD_LATENCY_SUM
 		name_count = turbo_POD_LATENCY_COUNT
+		name_bucket = turbo_POD_LATENCY_BUCKET
 	} else {
 		name_sum = turbo_SVC_LATENCY_SUM
 		name_count = turbo_SVC_LATENCY_COUNT
+		name_bucket = turbo_SVC_LATENCY_BUCKET
 	}
 
 	du := q.du
+	if q.quantile > 0 {
+		return "1000.0*" + histogramQuantileExp(q.quantile, name_bucket, `response_code="200"`, turbo_LATENCY_BY, du)
+	}
+
 	result := fmt.Sprintf("1000.0*rate(%v{response_code=\"200\"}[%v])/rate(%v{response_code=\"200\"}[%v])",
 		name_sum, du, name_count, du)
 	return result
@@ -278,6 +399,16 @@ func (q *istioQuery) getRPSExp(pod bool) string {
 	return result
 }
 
+// exp = sum by (destination_uid,destination_ip,response_code) (rate(turbo_request_count[3m]))
+func (q *istioQuery) getRequestsExp(pod bool) string {
+	name_count := turbo_SVC_REQUEST_COUNT
+	if pod {
+		name_count = turbo_POD_REQUEST_COUNT
+	}
+
+	return fmt.Sprintf("sum by (%v,%v) (rate(%v[%v]))", turbo_LATENCY_BY, responseCodeLabel, name_count, q.du)
+}
+
 func newIstioMetricData() *istioMetricData {
 	return &istioMetricData{
 		Labels: make(map[string]string),
@@ -291,6 +422,7 @@ func (d *istioMetricData) Parse(m *xfire.RawMetric) error {
 	}
 
 	labels := m.Labels
+	d.code = labels[responseCodeLabel]
 
 	//1. pod/svc Name
 	v, ok := labels["destination_uid"]
@@ -326,7 +458,7 @@ func (d *istioMetricData) Parse(m *xfire.RawMetric) error {
 }
 
 func (d *istioMetricData) parseUID(muid string) (string, error) {
-	if d.dtype < 2 {
+	if d.dtype < 2 || d.dtype == podRequests {
 		return convertPodUID(muid)
 	}
 
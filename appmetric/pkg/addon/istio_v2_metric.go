@@ -0,0 +1,312 @@
+package addon
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/util"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+	"strings"
+)
+
+// the standard metrics of Istio telemetry v2 (mixerless), emitted by the Envoy sidecars
+const (
+	istio_REQUESTS_TOTAL          = "istio_requests_total"
+	istio_REQUEST_DURATION_SUM    = "istio_request_duration_milliseconds_sum"
+	istio_REQUEST_DURATION_COUNT  = "istio_request_duration_milliseconds_count"
+	istio_REQUEST_DURATION_BUCKET = "istio_request_duration_milliseconds_bucket"
+
+	// labels of the destination
+	istioDstWorkload  = "destination_workload"
+	istioDstNamespace = "destination_workload_namespace"
+	istioDstService   = "destination_canonical_service"
+
+	// labels of the scraped sidecar: the pod IP and name
+	istioInstance         = "instance"
+	istioPod              = "pod"
+	istioLegacyPod        = "kubernetes_pod_name"
+	istioReporterSelector = `reporter="destination"`
+
+	// labels copied to the entities
+	istioWorkloadLabel  = "workload"
+	istioNamespaceLabel = "namespace"
+	istioServiceLabel   = "service"
+)
+
+// IstioV2EntityGetter builds the pod (Application) or service (VirtualApplication) entities
+// from the standard Istio telemetry v2 metrics, without custom Mixer rules.
+// The metrics reported by the destination sidecars are used, so the pods are identified by the scraped instance IP.
+type IstioV2EntityGetter struct {
+	name    string
+	du      string
+	etype   int //Pod(Application), or Service
+	latency *LatencyOption
+}
+
+// ensure IstioV2EntityGetter implement the requisite interfaces
+var _ alligator.EntityMetricGetter = &IstioV2EntityGetter{}
+var _ alligator.EdgeGetter = &IstioV2EntityGetter{}
+
+func newIstioV2EntityGetter(name, du string, isVirtualApp bool, latency *LatencyOption) *IstioV2EntityGetter {
+	g := &IstioV2EntityGetter{
+		name:    name,
+		du:      du,
+		etype:   podType,
+		latency: latency,
+	}
+
+	if isVirtualApp {
+		g.etype = svcType
+	}
+	return g
+}
+
+func (g *IstioV2EntityGetter) Name() string {
+	return g.name
+}
+
+func (g *IstioV2EntityGetter) Category() string {
+	if g.etype == podType {
+		return IstioV2GetterCategory
+	}
+
+	return IstioV2VAppGetterCategory
+}
+
+func (g *IstioV2EntityGetter) GetEntityMetric(client xfire.MetricClient) ([]*inter.EntityMetric, error) {
+	result := []*inter.EntityMetric{}
+	midResult := make(map[string]*inter.EntityMetric)
+
+	//1. get TPS data
+	query := xfire.NewBasicInput()
+	query.SetQuery(g.getRPSExp())
+	tpsDat, err := client.GetMetrics(query)
+	if err != nil {
+		glog.Errorf("Failed to get Istio TPS metrics: %v", err)
+		return result, err
+	}
+	g.addEntity(tpsDat, midResult, inter.TpsType)
+
+	//2. get Latency data
+	query = xfire.NewBasicInput()
+	query.SetQuery(g.getLatencyExp(g.latency.Quantile))
+	latencyDat, err := client.GetMetrics(query)
+	if err != nil {
+		glog.Errorf("Failed to get Istio Latency metrics: %v", err)
+		return result, err
+	}
+	g.addEntity(latencyDat, midResult, inter.LatencyType)
+
+	glog.V(4).Infof("len(TPS)=%d, len(Latency)=%d", len(tpsDat), len(latencyDat))
+
+	//3. the latencies of other quantiles as labels
+	for _, q := range g.latency.LabelQuantiles {
+		query = xfire.NewBasicInput()
+		query.SetQuery(g.getLatencyExp(q))
+		dat, err := client.GetMetrics(query)
+		if err != nil {
+			glog.Warningf("Failed to get Istio Latency metrics of quantile %v: %v", q, err)
+			continue
+		}
+		g.addLabel(dat, midResult, latencyLabel(q))
+	}
+
+	//4. the request rate and error rates by the class of response codes
+	query = xfire.NewBasicInput()
+	query.SetQuery(g.getRequestsExp())
+	requestDat, err := client.GetMetrics(query)
+	if err != nil {
+		glog.Warningf("Failed to get Istio request metrics by response code: %v", err)
+	} else {
+		g.addErrorRates(requestDat, midResult)
+	}
+
+	//5. reform map to list
+	for _, v := range midResult {
+		result = append(result, v)
+	}
+
+	return result, nil
+}
+
+// GetEdges gets the dependencies between the services;
+// no edges for the pods, as the metrics don't identify the calling pods.
+func (g *IstioV2EntityGetter) GetEdges(client xfire.MetricClient) ([]*inter.Edge, error) {
+	if g.etype != svcType {
+		return []*inter.Edge{}, nil
+	}
+
+	return istioV2EdgeDef.getEdges(client, g.du)
+}
+
+// addEntity creates entities from the metric data
+func (g *IstioV2EntityGetter) addEntity(mdat []xfire.MetricData, result map[string]*inter.EntityMetric, key proto.CommodityDTO_CommodityType) {
+	for _, dat := range mdat {
+		metric, ok := dat.(*xfire.BasicMetricData)
+		if !ok {
+			glog.Errorf("Type assertion failed for[%v].", key)
+			continue
+		}
+
+		uid, labels, err := g.parseLabels(metric.Labels)
+		if err != nil {
+			glog.V(3).Infof("Skip Istio metric %v: %v", metric.Labels, err)
+			continue
+		}
+
+		entity := g.getEntity(uid, labels, result)
+		entity.SetMetric(key, metric.GetValue())
+	}
+}
+
+// getEntity returns the entity of the uid, which is created if not exists
+func (g *IstioV2EntityGetter) getEntity(uid string, labels map[string]string, result map[string]*inter.EntityMetric) *inter.EntityMetric {
+	if entity, ok := result[uid]; ok {
+		return entity
+	}
+
+	etype := inter.AppEntity
+	if g.etype == svcType {
+		etype = inter.VAppEntity
+	}
+
+	entity := inter.NewEntityMetric(uid, etype)
+	for k, v := range labels {
+		entity.SetLabel(k, v)
+	}
+	entity.SetLabel(inter.Category, g.Category())
+	result[uid] = entity
+	return entity
+}
+
+// addErrorRates sets the request rate and error rates of the entities, from the request rates by response code
+func (g *IstioV2EntityGetter) addErrorRates(mdat []xfire.MetricData, result map[string]*inter.EntityMetric) {
+	rates := make(errorRates)
+	for _, dat := range mdat {
+		metric, ok := dat.(*xfire.BasicMetricData)
+		if !ok {
+			glog.Errorf("Type assertion failed for requests.")
+			continue
+		}
+
+		uid, labels, err := g.parseLabels(metric.Labels)
+		if err != nil {
+			glog.V(3).Infof("Skip Istio metric %v: %v", metric.Labels, err)
+			continue
+		}
+
+		g.getEntity(uid, labels, result)
+		rates.add(uid, metric.Labels[responseCodeLabel], metric.GetValue())
+	}
+	rates.apply(result)
+}
+
+// addLabel sets the metric values as a label of the existing entities
+func (g *IstioV2EntityGetter) addLabel(mdat []xfire.MetricData, result map[string]*inter.EntityMetric, name string) {
+	for _, dat := range mdat {
+		metric, ok := dat.(*xfire.BasicMetricData)
+		if !ok {
+			glog.Errorf("Type assertion failed for[%v].", name)
+			continue
+		}
+
+		uid, _, err := g.parseLabels(metric.Labels)
+		if err != nil {
+			continue
+		}
+
+		if entity, ok := result[uid]; ok {
+			entity.SetLabel(name, formatLatency(metric.GetValue()))
+		}
+	}
+}
+
+// parseLabels generates the entity UID and labels from the metric labels:
+// the UID of a pod is its IP; the UID of a service is "<namespace>/<canonical service>".
+func (g *IstioV2EntityGetter) parseLabels(mlabels map[string]string) (string, map[string]string, error) {
+	labels := make(map[string]string)
+
+	namespace := mlabels[istioDstNamespace]
+	service := mlabels[istioDstService]
+	if len(namespace) < 1 || namespace == "unknown" {
+		return "", nil, fmt.Errorf("unknown destination namespace")
+	}
+	labels[istioNamespaceLabel] = namespace
+
+	if len(service) > 0 && service != "unknown" {
+		labels[istioServiceLabel] = service
+	}
+
+	//1. service
+	if g.etype == svcType {
+		if _, ok := labels[istioServiceLabel]; !ok {
+			return "", nil, fmt.Errorf("unknown destination service")
+		}
+		uid := fmt.Sprintf("%s/%s", namespace, service)
+		labels[inter.Name] = uid
+		return uid, labels, nil
+	}
+
+	//2. pod
+	if workload := mlabels[istioDstWorkload]; len(workload) > 0 && workload != "unknown" {
+		labels[istioWorkloadLabel] = workload
+	}
+
+	addr, ok := mlabels[istioInstance]
+	if !ok {
+		return "", nil, fmt.Errorf("label %v is not found", istioInstance)
+	}
+
+	// the port of the scraped instance is the one of the sidecar, not the application
+	ip, _, err := util.ParseIP(addr, 0)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse IP from addr[%v]: %v", addr, err)
+	}
+	labels[inter.IP] = ip
+
+	pod := mlabels[istioPod]
+	if len(pod) < 1 {
+		pod = mlabels[istioLegacyPod]
+	}
+	if len(pod) > 0 {
+		labels[inter.Name] = fmt.Sprintf("%s/%s", namespace, pod)
+	}
+
+	return ip, labels, nil
+}
+
+// the labels to aggregate the metrics by
+func (g *IstioV2EntityGetter) groupBy() string {
+	if g.etype == svcType {
+		return strings.Join([]string{istioDstNamespace, istioDstService}, ",")
+	}
+
+	return strings.Join([]string{istioInstance, istioPod, istioLegacyPod,
+		istioDstNamespace, istioDstWorkload, istioDstService}, ",")
+}
+
+// exp = sum by (...) (rate(istio_requests_total{reporter="destination"}[3m]))
+func (g *IstioV2EntityGetter) getRPSExp() string {
+	return fmt.Sprintf("sum by (%v) (rate(%v{%v}[%v]))",
+		g.groupBy(), istio_REQUESTS_TOTAL, istioReporterSelector, g.du)
+}
+
+// exp = sum by (..., response_code) (rate(istio_requests_total{reporter="destination"}[3m]))
+func (g *IstioV2EntityGetter) getRequestsExp() string {
+	return fmt.Sprintf("sum by (%v,%v) (rate(%v{%v}[%v]))",
+		g.groupBy(), responseCodeLabel, istio_REQUESTS_TOTAL, istioReporterSelector, g.du)
+}
+
+// the latency in milliseconds: the quantile of the histogram, or the mean if quantile is 0
+func (g *IstioV2EntityGetter) getLatencyExp(quantile float64) string {
+	by := g.groupBy()
+	if quantile > 0 {
+		return histogramQuantileExp(quantile, istio_REQUEST_DURATION_BUCKET, istioReporterSelector, by, g.du)
+	}
+
+	return fmt.Sprintf("sum by (%v) (rate(%v{%v}[%v])) / sum by (%v) (rate(%v{%v}[%v]))",
+		by, istio_REQUEST_DURATION_SUM, istioReporterSelector, g.du,
+		by, istio_REQUEST_DURATION_COUNT, istioReporterSelector, g.du)
+}
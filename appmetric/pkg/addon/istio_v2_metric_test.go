@@ -0,0 +1,116 @@
+package addon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+func TestIstioV2EntityGetter_ParseLabels(t *testing.T) {
+	labels := map[string]string{
+		"instance":                       "10.2.1.84:15020",
+		"pod":                            "productpage-v1-7f44c4d57c-xrjtm",
+		"destination_workload":           "productpage-v1",
+		"destination_workload_namespace": "default",
+		"destination_canonical_service":  "productpage",
+	}
+
+	pod := newIstioV2EntityGetter("istio.app.metric", "3m", false, DefaultLatencyOption())
+	uid, plabels, err := pod.parseLabels(labels)
+	if err != nil || uid != "10.2.1.84" {
+		t.Errorf("Failed to parse pod labels: %v, %v", uid, err)
+	}
+	if plabels[inter.Name] != "default/productpage-v1-7f44c4d57c-xrjtm" || plabels[istioWorkloadLabel] != "productpage-v1" {
+		t.Errorf("Wrong pod labels: %+v", plabels)
+	}
+
+	svc := newIstioV2EntityGetter("istio.vapp.metric", "3m", true, DefaultLatencyOption())
+	uid, slabels, err := svc.parseLabels(labels)
+	if err != nil || uid != "default/productpage" || slabels[inter.IP] != "" {
+		t.Errorf("Failed to parse service labels: %v, %+v, %v", uid, slabels, err)
+	}
+
+	labels["destination_canonical_service"] = "unknown"
+	if _, _, err := svc.parseLabels(labels); err == nil {
+		t.Errorf("Unknown service should be skipped")
+	}
+}
+
+func TestIstioV2EntityGetter_GetEntityMetric(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value := "12"
+		if strings.Contains(r.URL.Query().Get("query"), istio_REQUEST_DURATION_SUM) {
+			value = "35.5"
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"instance":"10.2.1.84:15020","destination_workload_namespace":"default","destination_canonical_service":"productpage"},
+			 "value":[1530000000,"` + value + `"]}]}}`))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Errorf("Failed to create rest client: %v", err)
+		return
+	}
+
+	g := newIstioV2EntityGetter("istio.app.metric", "3m", false, DefaultLatencyOption())
+	result, err := g.GetEntityMetric(client)
+	if err != nil || len(result) != 1 {
+		t.Errorf("Failed to get entity metrics: %+v, %v", result, err)
+		return
+	}
+
+	e := result[0]
+	if e.Type != inter.AppEntity || e.Metrics[inter.TpsType] != 12 || e.Metrics[inter.LatencyType] != 35.5 {
+		t.Errorf("Wrong entity: %+v", e)
+	}
+	if e.Labels[inter.Category] != IstioV2GetterCategory {
+		t.Errorf("Wrong category: %v", e.Labels[inter.Category])
+	}
+}
+
+func TestIstioV2EntityGetter_LatencyQuantile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		value := "12"
+		switch {
+		case strings.HasPrefix(query, "histogram_quantile(0.99,"):
+			value = "250"
+		case strings.HasPrefix(query, "histogram_quantile(0.95,"):
+			value = "120"
+		case strings.Contains(query, istio_REQUEST_DURATION_SUM):
+			value = "35.5"
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"destination_workload_namespace":"default","destination_canonical_service":"productpage"},
+			 "value":[1530000000,"` + value + `"]}]}}`))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Errorf("Failed to create rest client: %v", err)
+		return
+	}
+
+	latency, _ := NewLatencyOption(0.95, []float64{0, 0.99})
+	g := newIstioV2EntityGetter("istio.vapp.metric", "3m", true, latency)
+	result, err := g.GetEntityMetric(client)
+	if err != nil || len(result) != 1 {
+		t.Errorf("Failed to get entity metrics: %+v, %v", result, err)
+		return
+	}
+
+	e := result[0]
+	if e.Metrics[inter.LatencyType] != 120 {
+		t.Errorf("Wrong latency: %v Vs. 120", e.Metrics[inter.LatencyType])
+	}
+	if e.Labels["latency_mean"] != "35.500" || e.Labels["latency_p99"] != "250.000" {
+		t.Errorf("Wrong latency labels: %+v", e.Labels)
+	}
+}
@@ -0,0 +1,75 @@
+package addon
+
+import (
+	"fmt"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+)
+
+// the JVM metrics of jmx_exporter (the hotspot collectors of the prometheus java client), and of Micrometer
+const (
+	jmx_MEMORY_USED   = "jvm_memory_bytes_used"
+	jmx_MEMORY_MAX    = "jvm_memory_bytes_max"
+	jmx_GC_SECONDS    = "jvm_gc_collection_seconds_sum"
+	jmx_THREADS       = "jvm_threads_current"
+	micro_MEMORY_USED = "jvm_memory_used_bytes"
+	micro_MEMORY_MAX  = "jvm_memory_max_bytes"
+	micro_GC_SECONDS  = "jvm_gc_pause_seconds_sum"
+	micro_THREADS     = "jvm_threads_live_threads"
+
+	// the HTTP server requests of Micrometer
+	micro_HTTP_SECONDS_SUM   = "http_server_requests_seconds_sum"
+	micro_HTTP_SECONDS_COUNT = "http_server_requests_seconds_count"
+
+	jvmHeapSelector = `area="heap"`
+
+	default_JVM_Port = 8080
+)
+
+// JVMEntityGetter builds the Java applications, identified by the pod IP, from the JVM metrics
+// of jmx_exporter or Micrometer: the heap usage in KB with the max heap as capacity,
+// the percentage of time spent in garbage collection, and the live threads.
+// TPS and latency are reported if the HTTP server requests are exported by Micrometer.
+type JVMEntityGetter struct {
+	instanceEntityGetter
+}
+
+// ensure JVMEntityGetter implement the requisite interfaces
+var _ alligator.EntityMetricGetter = &JVMEntityGetter{}
+
+func NewJVMEntityGetter(name, du string) *JVMEntityGetter {
+	return &JVMEntityGetter{
+		instanceEntityGetter{
+			name:        name,
+			category:    JVMGetterCategory,
+			entityType:  inter.AppEntity,
+			defaultPort: default_JVM_Port,
+			queries: []*instanceQuery{
+				{ctype: inter.HeapType, query: getJVMHeapExp(jmx_MEMORY_USED, micro_MEMORY_USED), required: true},
+				{ctype: inter.HeapType, query: getJVMHeapExp(jmx_MEMORY_MAX, micro_MEMORY_MAX), capacity: true},
+				{ctype: inter.CollectionTimeType, query: getJVMCollectionTimeExp(du)},
+				{ctype: inter.ThreadsType, query: eitherExp(sumByInstance(jmx_THREADS), sumByInstance(micro_THREADS))},
+				{ctype: inter.TpsType, query: sumRateByInstance(micro_HTTP_SECONDS_COUNT, du)},
+				{ctype: inter.LatencyType, query: getJVMLatencyExp(du)},
+			},
+		},
+	}
+}
+
+// the heap memory of all the pools in KB; the pools without max (-1) are skipped
+func getJVMHeapExp(jmx, micro string) string {
+	exp := func(metric string) string {
+		return fmt.Sprintf("sum by (%v) (%v{%v} > 0) / 1024", instanceLabel, metric, jvmHeapSelector)
+	}
+	return eitherExp(exp(jmx), exp(micro))
+}
+
+// the percentage of the time spent in the garbage collections
+func getJVMCollectionTimeExp(du string) string {
+	return eitherExp("100*"+sumRateByInstance(jmx_GC_SECONDS, du), "100*"+sumRateByInstance(micro_GC_SECONDS, du))
+}
+
+// the mean latency of the HTTP server requests in milliseconds
+func getJVMLatencyExp(du string) string {
+	return fmt.Sprintf("1000*%v / %v", sumRateByInstance(micro_HTTP_SECONDS_SUM, du), sumRateByInstance(micro_HTTP_SECONDS_COUNT, du))
+}
@@ -0,0 +1,40 @@
+package addon
+
+import (
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+)
+
+func TestJVMEntityGetter_GetEntityMetric(t *testing.T) {
+	e := getInstanceEntity(t, JVMGetterCategory, "10.2.3.7:8080", []metricValue{
+		{jmx_MEMORY_USED, "262144"},
+		{jmx_MEMORY_MAX, "1048576"},
+		{jmx_GC_SECONDS, "2.5"},
+		{jmx_THREADS, "48"},
+		{micro_HTTP_SECONDS_SUM, "85"},
+		{micro_HTTP_SECONDS_COUNT, "30"},
+	})
+
+	if e.UID != "10.2.3.7" || e.Type != inter.AppEntity {
+		t.Errorf("Wrong entity: %+v", e)
+	}
+	if e.Metrics[inter.HeapType] != 262144 || e.Capacities[inter.HeapType] != 1048576 {
+		t.Errorf("Wrong heap: %+v, %+v", e.Metrics, e.Capacities)
+	}
+	if e.Metrics[inter.CollectionTimeType] != 2.5 || e.Metrics[inter.ThreadsType] != 48 {
+		t.Errorf("Wrong JVM metrics: %+v", e.Metrics)
+	}
+	if e.Metrics[inter.TpsType] != 30 || e.Metrics[inter.LatencyType] != 85 {
+		t.Errorf("Wrong HTTP metrics: %+v", e.Metrics)
+	}
+}
+
+func TestGetJVMHeapExp(t *testing.T) {
+	exp := getJVMHeapExp(jmx_MEMORY_USED, micro_MEMORY_USED)
+	expected := `(sum by (instance) (jvm_memory_bytes_used{area="heap"} > 0) / 1024) or ` +
+		`(sum by (instance) (jvm_memory_used_bytes{area="heap"} > 0) / 1024)`
+	if exp != expected {
+		t.Errorf("Wrong query: %v", exp)
+	}
+}
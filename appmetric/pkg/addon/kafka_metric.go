@@ -0,0 +1,115 @@
+package addon
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+// the broker metrics of the JMX exporter (with the rules of Strimzi), and the consumer metrics of kafka_exporter
+const (
+	kafka_MESSAGES_IN     = "kafka_server_brokertopicmetrics_messagesin_total"
+	kafka_BYTES_IN        = "kafka_server_brokertopicmetrics_bytesin_total"
+	kafka_BYTES_OUT       = "kafka_server_brokertopicmetrics_bytesout_total"
+	kafka_REQUEST_TIME    = "kafka_network_requestmetrics_totaltimems"
+	kafka_CONSUMERGRP_LAG = "kafka_consumergroup_lag"
+
+	// the latency of the produce and fetch requests
+	kafkaRequestSelector = `request=~"Produce|FetchConsumer",quantile="0.99"`
+
+	kafkaConsumerGroup = "consumergroup"
+	kafkaTopic         = "topic"
+
+	default_Kafka_Port = 9404
+
+	// the consumer lag is attached to the pods whose workload is the consumer group
+	DefaultKafkaConsumerLabel = istioWorkloadLabel
+)
+
+// KafkaEntityGetter builds the Kafka brokers from the metrics of the JMX exporter:
+// the incoming message rate, the 99th percentile latency of the produce and fetch requests,
+// and the rates of incoming and outgoing bytes as attributes.
+// The lag of the consumer groups, from kafka_exporter, is attached to the consuming applications,
+// whose label (e.g., "workload") is the consumer group.
+type KafkaEntityGetter struct {
+	instanceEntityGetter
+	consumerLabel string
+}
+
+// ensure KafkaEntityGetter implement the requisite interfaces
+var _ alligator.EntityMetricGetter = &KafkaEntityGetter{}
+var _ alligator.AttachmentGetter = &KafkaEntityGetter{}
+
+func NewKafkaEntityGetter(name, du, consumerLabel string) *KafkaEntityGetter {
+	return &KafkaEntityGetter{
+		instanceEntityGetter: instanceEntityGetter{
+			name:        name,
+			category:    KafkaGetterCategory,
+			entityType:  inter.AppEntity,
+			defaultPort: default_Kafka_Port,
+			queries: []*instanceQuery{
+				{ctype: inter.TpsType, query: sumRateByInstance(kafka_MESSAGES_IN, du), required: true},
+				{ctype: inter.LatencyType, query: getKafkaLatencyExp()},
+				{attribute: inter.BytesInRate, query: sumRateByInstance(kafka_BYTES_IN, du)},
+				{attribute: inter.BytesOutRate, query: sumRateByInstance(kafka_BYTES_OUT, du)},
+			},
+		},
+		consumerLabel: consumerLabel,
+	}
+}
+
+// GetAttachments gets the lag of every consumer group, both in total and by topic
+func (g *KafkaEntityGetter) GetAttachments(client xfire.MetricClient) ([]*inter.Attachment, error) {
+	result := []*inter.Attachment{}
+	if len(g.consumerLabel) < 1 {
+		return result, nil
+	}
+
+	query := xfire.NewBasicInput()
+	query.SetQuery(getKafkaLagExp())
+	mdat, err := client.GetMetrics(query)
+	if err != nil {
+		glog.Errorf("Failed to get Kafka consumer lag metrics: %v", err)
+		return result, err
+	}
+
+	groups := make(map[string]*inter.Attachment)
+	for _, dat := range mdat {
+		metric, ok := dat.(*xfire.BasicMetricData)
+		if !ok {
+			glog.Errorf("Type assertion failed for consumer lag.")
+			continue
+		}
+
+		group := metric.Labels[kafkaConsumerGroup]
+		topic := metric.Labels[kafkaTopic]
+		if len(group) < 1 || len(topic) < 1 {
+			glog.V(3).Infof("Skip Kafka metric %v: no consumer group or topic", metric.Labels)
+			continue
+		}
+
+		a, ok := groups[group]
+		if !ok {
+			a = inter.NewAttachment(g.consumerLabel, group)
+			groups[group] = a
+			result = append(result, a)
+		}
+		a.Attributes[inter.ConsumerLag] += metric.GetValue()
+		a.Attributes[inter.ConsumerLag+uidSeparator+topic] = metric.GetValue()
+	}
+	glog.V(4).Infof("len(ConsumerGroups)=%d", len(result))
+
+	return result, nil
+}
+
+// the 99th percentile latency of the requests in milliseconds, the max of the request types
+func getKafkaLatencyExp() string {
+	return fmt.Sprintf("max by (%v) (%v{%v})", instanceLabel, kafka_REQUEST_TIME, kafkaRequestSelector)
+}
+
+// exp = sum by (consumergroup, topic) (kafka_consumergroup_lag)
+func getKafkaLagExp() string {
+	return fmt.Sprintf("sum by (%v,%v) (%v)", kafkaConsumerGroup, kafkaTopic, kafka_CONSUMERGRP_LAG)
+}
@@ -0,0 +1,68 @@
+package addon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+func TestKafkaEntityGetter_GetEntityMetric(t *testing.T) {
+	e := getInstanceEntity(t, KafkaGetterCategory, "10.2.5.20", []metricValue{
+		{kafka_MESSAGES_IN, "1200"},
+		{kafka_REQUEST_TIME, "15"},
+		{kafka_BYTES_IN, "204800"},
+		{kafka_BYTES_OUT, "409600"},
+	})
+
+	if e.UID != "10.2.5.20" || e.Labels[inter.Port] != "9404" {
+		t.Errorf("Wrong entity: %+v", e)
+	}
+	if e.Metrics[inter.TpsType] != 1200 || e.Metrics[inter.LatencyType] != 15 {
+		t.Errorf("Wrong metrics: %+v", e.Metrics)
+	}
+	if e.Attributes[inter.BytesInRate] != 204800 || e.Attributes[inter.BytesOutRate] != 409600 {
+		t.Errorf("Wrong attributes: %+v", e.Attributes)
+	}
+}
+
+func TestKafkaEntityGetter_GetAttachments(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"consumergroup":"billing","topic":"orders"},"value":[1530000000,"120"]},
+			{"metric":{"consumergroup":"billing","topic":"refunds"},"value":[1530000000,"30"]},
+			{"metric":{"consumergroup":"shipping","topic":"orders"},"value":[1530000000,"7"]},
+			{"metric":{"topic":"orders"},"value":[1530000000,"1"]}]}}`))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+
+	g := NewKafkaEntityGetter("kafka.app.metric", "3m", "app")
+	result, err := g.GetAttachments(client)
+	if err != nil || len(result) != 2 {
+		t.Fatalf("Failed to get attachments: %+v, %v", result, err)
+	}
+
+	groups := make(map[string]*inter.Attachment)
+	for _, a := range result {
+		if a.Label != "app" {
+			t.Errorf("Wrong label of attachment: %+v", a)
+		}
+		groups[a.Value] = a
+	}
+
+	billing := groups["billing"]
+	if billing == nil || billing.Attributes[inter.ConsumerLag] != 150 ||
+		billing.Attributes[inter.ConsumerLag+"/orders"] != 120 || billing.Attributes[inter.ConsumerLag+"/refunds"] != 30 {
+		t.Errorf("Wrong lag of group billing: %+v", billing)
+	}
+	if shipping := groups["shipping"]; shipping == nil || shipping.Attributes[inter.ConsumerLag] != 7 {
+		t.Errorf("Wrong lag of group shipping: %+v", shipping)
+	}
+}
@@ -0,0 +1,59 @@
+package addon
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	latencyLabelPrefix = "latency_"
+	latencyMeanLabel   = "latency_mean"
+)
+
+// LatencyOption decides how the latency (RESPONSE_TIME) is computed by the getters of histogram metrics
+type LatencyOption struct {
+	// the quantile in (0, 1), e.g., 0.95, computed by histogram_quantile() from the buckets;
+	// 0 for the mean latency: rate(sum)/rate(count)
+	Quantile float64
+
+	// the latencies reported as labels for comparison, e.g., "latency_mean" for 0, and "latency_p99" for 0.99
+	LabelQuantiles []float64
+}
+
+func NewLatencyOption(quantile float64, labelQuantiles []float64) (*LatencyOption, error) {
+	for _, q := range append([]float64{quantile}, labelQuantiles...) {
+		if q < 0 || q >= 1 {
+			return nil, fmt.Errorf("Invalid latency quantile %v, should be in (0, 1), or 0 for the mean", q)
+		}
+	}
+
+	return &LatencyOption{
+		Quantile:       quantile,
+		LabelQuantiles: labelQuantiles,
+	}, nil
+}
+
+// DefaultLatencyOption reports the mean latency
+func DefaultLatencyOption() *LatencyOption {
+	return &LatencyOption{}
+}
+
+// latencyLabel returns the label name of the latency quantile, e.g., "latency_p95", or "latency_mean" for 0
+func latencyLabel(quantile float64) string {
+	if quantile == 0 {
+		return latencyMeanLabel
+	}
+	return latencyLabelPrefix + "p" + strconv.FormatFloat(quantile*100, 'f', -1, 64)
+}
+
+// formatLatency formats the latency as a label value
+func formatLatency(v float64) string {
+	return strconv.FormatFloat(v, 'f', 3, 64)
+}
+
+// histogramQuantileExp generates the query of the quantile from the buckets of a histogram, for example:
+// histogram_quantile(0.95, sum by (le, instance) (rate(istio_request_duration_milliseconds_bucket{reporter="destination"}[3m])))
+func histogramQuantileExp(quantile float64, bucket, selector, by, du string) string {
+	return fmt.Sprintf("histogram_quantile(%v, sum by (le,%v) (rate(%v{%v}[%v])))",
+		strconv.FormatFloat(quantile, 'f', -1, 64), by, bucket, selector, du)
+}
@@ -0,0 +1,42 @@
+package addon
+
+import (
+	"testing"
+)
+
+func TestLatencyLabel(t *testing.T) {
+	expects := map[float64]string{
+		0:     "latency_mean",
+		0.5:   "latency_p50",
+		0.95:  "latency_p95",
+		0.999: "latency_p99.9",
+	}
+
+	for q, expect := range expects {
+		if label := latencyLabel(q); label != expect {
+			t.Errorf("Wrong label of quantile %v: %v Vs. %v", q, label, expect)
+		}
+	}
+}
+
+func TestNewLatencyOption(t *testing.T) {
+	if _, err := NewLatencyOption(0.95, []float64{0, 0.99}); err != nil {
+		t.Errorf("Failed to create latency option: %v", err)
+	}
+
+	for _, q := range []float64{-0.1, 1, 95} {
+		if _, err := NewLatencyOption(q, nil); err == nil {
+			t.Errorf("Invalid quantile %v is accepted", q)
+		}
+	}
+}
+
+func TestIstioQuery_Quantile(t *testing.T) {
+	q := newIstioQuery("3m", 0.99)
+	q.SetQueryType(podLatency)
+
+	expect := `1000.0*histogram_quantile(0.99, sum by (le,destination_uid,destination_ip) (rate(istio_turbo_pod_latency_time_ms_bucket{response_code="200"}[3m])))`
+	if exp := q.GetQuery(); exp != expect {
+		t.Errorf("Wrong latency query:\n%v\nVs.\n%v", exp, expect)
+	}
+}
@@ -0,0 +1,48 @@
+package addon
+
+import (
+	"fmt"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+)
+
+// the metrics of mongodb_exporter
+const (
+	mongo_OP_COUNTERS      = "mongodb_op_counters_total"
+	mongo_OP_LATENCY_TOTAL = "mongodb_mongod_op_latencies_latency_total"
+	mongo_OP_OPS_TOTAL     = "mongodb_mongod_op_latencies_ops_total"
+	mongo_CONNECTIONS      = "mongodb_connections"
+
+	default_MongoDB_Port = 9216
+)
+
+// MongoDBEntityGetter builds the MongoDB servers from the metrics of mongodb_exporter:
+// the operation rate, the operation latency, and the current connections.
+type MongoDBEntityGetter struct {
+	instanceEntityGetter
+}
+
+// ensure MongoDBEntityGetter implement the requisite interfaces
+var _ alligator.EntityMetricGetter = &MongoDBEntityGetter{}
+
+func NewMongoDBEntityGetter(name, du string) *MongoDBEntityGetter {
+	return &MongoDBEntityGetter{
+		instanceEntityGetter{
+			name:        name,
+			category:    MongoDBGetterCategory,
+			entityType:  inter.AppEntity,
+			defaultPort: default_MongoDB_Port,
+			queries: []*instanceQuery{
+				{ctype: inter.TpsType, query: sumRateByInstance(mongo_OP_COUNTERS, du), required: true},
+				{ctype: inter.LatencyType, query: getMongoDBLatencyExp(du)},
+				{ctype: inter.ConnectionType, query: sumByInstance(mongo_CONNECTIONS + `{state="current"}`)},
+				{ctype: inter.ConnectionType, query: sumByInstance(mongo_CONNECTIONS + `{state=~"current|available"}`), capacity: true},
+			},
+		},
+	}
+}
+
+// the mean latency of the operations in milliseconds; the latency of mongod is in microseconds
+func getMongoDBLatencyExp(du string) string {
+	return fmt.Sprintf("0.001*%v / %v", sumRateByInstance(mongo_OP_LATENCY_TOTAL, du), sumRateByInstance(mongo_OP_OPS_TOTAL, du))
+}
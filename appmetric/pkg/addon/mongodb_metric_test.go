@@ -0,0 +1,26 @@
+package addon
+
+import (
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+)
+
+func TestMongoDBEntityGetter_GetEntityMetric(t *testing.T) {
+	e := getInstanceEntity(t, MongoDBGetterCategory, "10.2.5.19:9216", []metricValue{
+		{mongo_OP_COUNTERS, "250"},
+		{mongo_OP_LATENCY_TOTAL, "1.2"},
+		{`state="current"`, "35"},
+		{`state=~"current|available"`, "838860"},
+	})
+
+	if e.UID != "10.2.5.19" || e.Labels[inter.Port] != "9216" {
+		t.Errorf("Wrong entity: %+v", e)
+	}
+	if e.Metrics[inter.TpsType] != 250 || e.Metrics[inter.LatencyType] != 1.2 {
+		t.Errorf("Wrong metrics: %+v", e.Metrics)
+	}
+	if e.Metrics[inter.ConnectionType] != 35 || e.Capacities[inter.ConnectionType] != 838860 {
+		t.Errorf("Wrong connections: %+v, %+v", e.Metrics, e.Capacities)
+	}
+}
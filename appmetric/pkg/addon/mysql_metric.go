@@ -0,0 +1,58 @@
+package addon
+
+import (
+	"fmt"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+)
+
+// the metrics of mysqld_exporter
+const (
+	mysql_QUESTIONS                 = "mysql_global_status_questions"
+	mysql_QUERIES                   = "mysql_global_status_queries"
+	mysql_THREADS_CONNECTED         = "mysql_global_status_threads_connected"
+	mysql_MAX_CONNECTIONS           = "mysql_global_variables_max_connections"
+	mysql_BUFFER_POOL_READS         = "mysql_global_status_innodb_buffer_pool_reads"
+	mysql_BUFFER_POOL_READ_REQUESTS = "mysql_global_status_innodb_buffer_pool_read_requests"
+
+	default_MySQL_Port = 9104
+)
+
+// MySQLEntityGetter builds the MySQL servers from the metrics of mysqld_exporter:
+// TPS from the statements sent by the clients, the connections, and the InnoDB buffer pool hit ratio;
+// the rate of all the statements, including the ones executed by the stored programs, is reported as an attribute.
+type MySQLEntityGetter struct {
+	instanceEntityGetter
+}
+
+// ensure MySQLEntityGetter implement the requisite interfaces
+var _ alligator.EntityMetricGetter = &MySQLEntityGetter{}
+
+func NewMySQLEntityGetter(name, du string) *MySQLEntityGetter {
+	return &MySQLEntityGetter{
+		instanceEntityGetter{
+			name:        name,
+			category:    MySQLGetterCategory,
+			entityType:  inter.AppEntity,
+			defaultPort: default_MySQL_Port,
+			queries: []*instanceQuery{
+				{ctype: inter.TpsType, query: getMySQLTPSExp(du), required: true},
+				{ctype: inter.ConnectionType, query: mysql_THREADS_CONNECTED},
+				{ctype: inter.ConnectionType, query: mysql_MAX_CONNECTIONS, capacity: true},
+				{ctype: inter.DBCacheHitRateType, query: getMySQLBufferPoolHitExp(du)},
+				{attribute: inter.QueriesRate, query: sumRateByInstance(mysql_QUERIES, du)},
+			},
+		},
+	}
+}
+
+// exp = sum by (instance) (rate(mysql_global_status_questions[3m]))
+func getMySQLTPSExp(du string) string {
+	return sumRateByInstance(mysql_QUESTIONS, du)
+}
+
+// the percentage of the InnoDB buffer pool read requests served without reading from the disk
+func getMySQLBufferPoolHitExp(du string) string {
+	return fmt.Sprintf("100*(1 - rate(%v[%v]) / rate(%v[%v]))",
+		mysql_BUFFER_POOL_READS, du, mysql_BUFFER_POOL_READ_REQUESTS, du)
+}
@@ -0,0 +1,66 @@
+package addon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+func TestMySQLEntityGetter_GetEntityMetric(t *testing.T) {
+	values := map[string]string{
+		mysql_QUESTIONS:         "120",
+		mysql_THREADS_CONNECTED: "15",
+		mysql_MAX_CONNECTIONS:   "151",
+		mysql_BUFFER_POOL_READS: "99.5",
+		mysql_QUERIES:           "135",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		value := ""
+		for name, v := range values {
+			if strings.Contains(query, name) {
+				value = v
+			}
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"instance":"10.2.5.17:9104"},"value":[1530000000,"` + value + `"]}]}}`))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Errorf("Failed to create rest client: %v", err)
+		return
+	}
+
+	g, err := NewGetterFactory().CreateEntityGetter(MySQLGetterCategory, "mysql.app.metric", "3m")
+	if err != nil {
+		t.Errorf("Failed to create MySQL getter: %v", err)
+		return
+	}
+
+	result, err := g.GetEntityMetric(client)
+	if err != nil || len(result) != 1 {
+		t.Errorf("Failed to get entity metrics: %+v, %v", result, err)
+		return
+	}
+
+	e := result[0]
+	if e.UID != "10.2.5.17" || e.Labels[inter.Port] != "9104" || e.Labels[inter.Category] != MySQLGetterCategory {
+		t.Errorf("Wrong entity: %+v", e)
+	}
+	if e.Metrics[inter.TpsType] != 120 || e.Metrics[inter.DBCacheHitRateType] != 99.5 {
+		t.Errorf("Wrong metrics: %+v", e.Metrics)
+	}
+	if e.Metrics[inter.ConnectionType] != 15 || e.Capacities[inter.ConnectionType] != 151 {
+		t.Errorf("Wrong connections: %+v, %+v", e.Metrics, e.Capacities)
+	}
+	if e.Attributes[inter.QueriesRate] != 135 {
+		t.Errorf("Wrong queries rate: %+v", e.Attributes)
+	}
+}
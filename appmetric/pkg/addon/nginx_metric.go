@@ -0,0 +1,254 @@
+package addon
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+	"strconv"
+	"strings"
+)
+
+// the metrics of the ingress-nginx controller
+const (
+	nginx_REQUESTS                = "nginx_ingress_controller_requests"
+	nginx_REQUEST_DURATION_SUM    = "nginx_ingress_controller_request_duration_seconds_sum"
+	nginx_REQUEST_DURATION_COUNT  = "nginx_ingress_controller_request_duration_seconds_count"
+	nginx_REQUEST_DURATION_BUCKET = "nginx_ingress_controller_request_duration_seconds_bucket"
+
+	// labels of the backend service; the namespace of the ingress is renamed to
+	// "exported_namespace" if it conflicts with the namespace of the scraped controller pod
+	nginxService           = "service"
+	nginxNamespace         = "namespace"
+	nginxExportedNamespace = "exported_namespace"
+	nginxStatus            = "status"
+
+	// the namespace of the backend service: "exported_namespace" if set, otherwise "namespace";
+	// the metrics are aggregated by it, so the requests of several controllers to the same service are summed up
+	nginxServiceNamespace = "service_namespace"
+
+	// requests not routed to any backend service are dropped
+	nginxServiceSelector = `service!=""`
+)
+
+// NginxIngressEntityGetter builds the backend services (VirtualApplication) of the ingresses
+// from the metrics of the ingress-nginx controller, for the clusters without Istio.
+// The services are identified by "<namespace>/<service>", the same as the Istio service getters, so that a service
+// is one entity on the clusters running both: its metrics from the two getters are resolved by the merge policy.
+type NginxIngressEntityGetter struct {
+	name    string
+	du      string
+	latency *LatencyOption
+}
+
+// ensure NginxIngressEntityGetter implement the requisite interfaces
+var _ alligator.EntityMetricGetter = &NginxIngressEntityGetter{}
+
+func NewNginxIngressEntityGetter(name, du string, latency *LatencyOption) *NginxIngressEntityGetter {
+	return &NginxIngressEntityGetter{
+		name:    name,
+		du:      du,
+		latency: latency,
+	}
+}
+
+func (g *NginxIngressEntityGetter) Name() string {
+	return g.name
+}
+
+func (g *NginxIngressEntityGetter) Category() string {
+	return NginxIngressVAppGetterCategory
+}
+
+func (g *NginxIngressEntityGetter) GetEntityMetric(client xfire.MetricClient) ([]*inter.EntityMetric, error) {
+	result := []*inter.EntityMetric{}
+	midResult := make(map[string]*inter.EntityMetric)
+
+	//1. get TPS data
+	query := xfire.NewBasicInput()
+	query.SetQuery(g.getRPSExp())
+	tpsDat, err := client.GetMetrics(query)
+	if err != nil {
+		glog.Errorf("Failed to get ingress-nginx TPS metrics: %v", err)
+		return result, err
+	}
+	g.addEntity(tpsDat, midResult, inter.TpsType)
+
+	//2. get Latency data
+	query = xfire.NewBasicInput()
+	query.SetQuery(g.getLatencyExp(g.latency.Quantile))
+	latencyDat, err := client.GetMetrics(query)
+	if err != nil {
+		glog.Errorf("Failed to get ingress-nginx Latency metrics: %v", err)
+		return result, err
+	}
+	g.addEntity(latencyDat, midResult, inter.LatencyType)
+
+	glog.V(4).Infof("len(TPS)=%d, len(Latency)=%d", len(tpsDat), len(latencyDat))
+
+	//3. the latencies of other quantiles as labels
+	for _, q := range g.latency.LabelQuantiles {
+		query = xfire.NewBasicInput()
+		query.SetQuery(g.getLatencyExp(q))
+		dat, err := client.GetMetrics(query)
+		if err != nil {
+			glog.Warningf("Failed to get ingress-nginx Latency metrics of quantile %v: %v", q, err)
+			continue
+		}
+		g.addLabel(dat, midResult, latencyLabel(q))
+	}
+
+	//4. the request rate and error rates by the class of status codes
+	query = xfire.NewBasicInput()
+	query.SetQuery(g.getRequestsExp())
+	requestDat, err := client.GetMetrics(query)
+	if err != nil {
+		glog.Warningf("Failed to get ingress-nginx request metrics by status: %v", err)
+	} else {
+		g.addErrorRates(requestDat, midResult)
+	}
+
+	//5. reform map to list
+	for _, v := range midResult {
+		result = append(result, v)
+	}
+
+	return result, nil
+}
+
+// addEntity creates entities from the metric data
+func (g *NginxIngressEntityGetter) addEntity(mdat []xfire.MetricData, result map[string]*inter.EntityMetric, key proto.CommodityDTO_CommodityType) {
+	for _, dat := range mdat {
+		metric, ok := dat.(*xfire.BasicMetricData)
+		if !ok {
+			glog.Errorf("Type assertion failed for[%v].", key)
+			continue
+		}
+
+		uid, labels, err := g.parseLabels(metric.Labels)
+		if err != nil {
+			glog.V(3).Infof("Skip ingress-nginx metric %v: %v", metric.Labels, err)
+			continue
+		}
+
+		entity := g.getEntity(uid, labels, result)
+		entity.SetMetric(key, metric.GetValue())
+	}
+}
+
+// getEntity returns the entity of the uid, which is created if not exists
+func (g *NginxIngressEntityGetter) getEntity(uid string, labels map[string]string, result map[string]*inter.EntityMetric) *inter.EntityMetric {
+	if entity, ok := result[uid]; ok {
+		return entity
+	}
+
+	entity := inter.NewEntityMetric(uid, inter.VAppEntity)
+	for k, v := range labels {
+		entity.SetLabel(k, v)
+	}
+	entity.SetLabel(inter.Category, g.Category())
+	result[uid] = entity
+	return entity
+}
+
+// addErrorRates sets the request rate and error rates of the entities, from the request rates by status
+func (g *NginxIngressEntityGetter) addErrorRates(mdat []xfire.MetricData, result map[string]*inter.EntityMetric) {
+	rates := make(errorRates)
+	for _, dat := range mdat {
+		metric, ok := dat.(*xfire.BasicMetricData)
+		if !ok {
+			glog.Errorf("Type assertion failed for requests.")
+			continue
+		}
+
+		uid, labels, err := g.parseLabels(metric.Labels)
+		if err != nil {
+			glog.V(3).Infof("Skip ingress-nginx metric %v: %v", metric.Labels, err)
+			continue
+		}
+
+		g.getEntity(uid, labels, result)
+		rates.add(uid, metric.Labels[nginxStatus], metric.GetValue())
+	}
+	rates.apply(result)
+}
+
+// addLabel sets the metric values as a label of the existing entities
+func (g *NginxIngressEntityGetter) addLabel(mdat []xfire.MetricData, result map[string]*inter.EntityMetric, name string) {
+	for _, dat := range mdat {
+		metric, ok := dat.(*xfire.BasicMetricData)
+		if !ok {
+			glog.Errorf("Type assertion failed for[%v].", name)
+			continue
+		}
+
+		uid, _, err := g.parseLabels(metric.Labels)
+		if err != nil {
+			continue
+		}
+
+		if entity, ok := result[uid]; ok {
+			entity.SetLabel(name, formatLatency(metric.GetValue()))
+		}
+	}
+}
+
+// parseLabels generates the entity UID "<namespace>/<service>" and labels from the metric labels
+func (g *NginxIngressEntityGetter) parseLabels(mlabels map[string]string) (string, map[string]string, error) {
+	service := mlabels[nginxService]
+	if len(service) < 1 {
+		return "", nil, fmt.Errorf("label %v is not found", nginxService)
+	}
+
+	namespace := mlabels[nginxServiceNamespace]
+	if len(namespace) < 1 {
+		return "", nil, fmt.Errorf("label %v is not found", nginxServiceNamespace)
+	}
+
+	uid := fmt.Sprintf("%s/%s", namespace, service)
+	labels := map[string]string{
+		istioNamespaceLabel: namespace,
+		istioServiceLabel:   service,
+		inter.Name:          uid,
+	}
+	return uid, labels, nil
+}
+
+// the labels to aggregate the metrics by
+func (g *NginxIngressEntityGetter) groupBy() string {
+	return strings.Join([]string{nginxServiceNamespace, nginxService}, ",")
+}
+
+// the rate of the metric, with the namespace of the backend service as the label "service_namespace":
+// label_replace(label_replace(rate(metric{service!=""}[3m]), "service_namespace", "$1", "namespace", "(.*)"),
+// "service_namespace", "$1", "exported_namespace", "(.+)")
+func (g *NginxIngressEntityGetter) rateExp(metric string) string {
+	rate := fmt.Sprintf("rate(%v{%v}[%v])", metric, nginxServiceSelector, g.du)
+	rate = fmt.Sprintf(`label_replace(%v, "%v", "$1", "%v", "(.*)")`, rate, nginxServiceNamespace, nginxNamespace)
+	return fmt.Sprintf(`label_replace(%v, "%v", "$1", "%v", "(.+)")`, rate, nginxServiceNamespace, nginxExportedNamespace)
+}
+
+// exp = sum by (service_namespace,service) (<rate of nginx_ingress_controller_requests>)
+func (g *NginxIngressEntityGetter) getRPSExp() string {
+	return fmt.Sprintf("sum by (%v) (%v)", g.groupBy(), g.rateExp(nginx_REQUESTS))
+}
+
+// exp = sum by (service_namespace,service,status) (<rate of nginx_ingress_controller_requests>)
+func (g *NginxIngressEntityGetter) getRequestsExp() string {
+	return fmt.Sprintf("sum by (%v,%v) (%v)", g.groupBy(), nginxStatus, g.rateExp(nginx_REQUESTS))
+}
+
+// the latency in milliseconds: the quantile of the histogram, or the mean if quantile is 0;
+// the durations of ingress-nginx are in seconds
+func (g *NginxIngressEntityGetter) getLatencyExp(quantile float64) string {
+	by := g.groupBy()
+	if quantile > 0 {
+		return fmt.Sprintf("1000*histogram_quantile(%v, sum by (le,%v) (%v))",
+			strconv.FormatFloat(quantile, 'f', -1, 64), by, g.rateExp(nginx_REQUEST_DURATION_BUCKET))
+	}
+
+	return fmt.Sprintf("1000*sum by (%v) (%v) / sum by (%v) (%v)",
+		by, g.rateExp(nginx_REQUEST_DURATION_SUM), by, g.rateExp(nginx_REQUEST_DURATION_COUNT))
+}
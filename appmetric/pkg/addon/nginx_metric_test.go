@@ -0,0 +1,137 @@
+package addon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+func TestNginxIngressEntityGetter_ParseLabels(t *testing.T) {
+	g := NewNginxIngressEntityGetter("nginx.vapp.metric", "3m", DefaultLatencyOption())
+
+	uid, labels, err := g.parseLabels(map[string]string{"service_namespace": "shop", "service": "cart"})
+	if err != nil || uid != "shop/cart" || labels[inter.Name] != "shop/cart" || labels[istioServiceLabel] != "cart" {
+		t.Errorf("Failed to parse labels: %v, %+v, %v", uid, labels, err)
+	}
+
+	if _, _, err := g.parseLabels(map[string]string{"service_namespace": "shop"}); err == nil {
+		t.Errorf("Metric without service should be skipped")
+	}
+
+	if _, _, err := g.parseLabels(map[string]string{"namespace": "shop", "service": "cart"}); err == nil {
+		t.Errorf("Metric not aggregated by the service namespace should be skipped")
+	}
+}
+
+func TestNginxIngressEntityGetter_GetEntityMetric(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		switch {
+		case strings.Contains(query, nginxStatus):
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"service_namespace":"shop","service":"cart","status":"200"},"value":[1530000000,"18"]},
+				{"metric":{"service_namespace":"shop","service":"cart","status":"503"},"value":[1530000000,"2"]}]}}`))
+			return
+		case strings.Contains(query, nginx_REQUEST_DURATION_SUM):
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"service_namespace":"shop","service":"cart"},"value":[1530000000,"42.5"]}]}}`))
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"service_namespace":"shop","service":"cart"},"value":[1530000000,"20"]}]}}`))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+
+	g, err := NewGetterFactory().CreateEntityGetter(NginxIngressVAppGetterCategory, "nginx.vapp.metric", "3m")
+	if err != nil {
+		t.Fatalf("Failed to create getter: %v", err)
+	}
+
+	result, err := g.GetEntityMetric(client)
+	if err != nil || len(result) != 1 {
+		t.Fatalf("Failed to get entity metrics: %+v, %v", result, err)
+	}
+
+	e := result[0]
+	if e.UID != "shop/cart" || e.Type != inter.VAppEntity || e.Labels[inter.Category] != NginxIngressVAppGetterCategory {
+		t.Errorf("Wrong entity: %+v", e)
+	}
+	if e.Metrics[inter.TpsType] != 20 || e.Metrics[inter.LatencyType] != 42.5 {
+		t.Errorf("Wrong metrics: %+v", e.Metrics)
+	}
+	if e.Attributes[inter.RequestRate] != 20 || e.Attributes[inter.ErrorRate5xx] != 0.1 {
+		t.Errorf("Wrong attributes: %+v", e.Attributes)
+	}
+}
+
+func TestNginxIngressEntityGetter_LatencyExp(t *testing.T) {
+	latency, _ := NewLatencyOption(0.95, nil)
+	g := NewNginxIngressEntityGetter("nginx.vapp.metric", "3m", latency)
+
+	exp := g.getLatencyExp(0.95)
+	expected := `1000*histogram_quantile(0.95, sum by (le,service_namespace,service) ` +
+		`(label_replace(label_replace(rate(nginx_ingress_controller_request_duration_seconds_bucket{service!=""}[3m]), ` +
+		`"service_namespace", "$1", "namespace", "(.*)"), "service_namespace", "$1", "exported_namespace", "(.+)")))`
+	if exp != expected {
+		t.Errorf("Wrong query: %v", exp)
+	}
+}
+
+// two controllers in different namespaces route the requests to the same service:
+// the series of the controllers are aggregated into the one of the service
+func TestNginxIngressEntityGetter_TwoControllers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		if !strings.Contains(query, "by (service_namespace,service)") &&
+			!strings.Contains(query, "by (le,service_namespace,service)") &&
+			!strings.Contains(query, "by (service_namespace,service,status)") {
+			// the series of every controller, as returned if the query is not aggregated by the service namespace
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"namespace":"ingress-a","exported_namespace":"shop","service":"cart"},"value":[1530000000,"10"]},
+				{"metric":{"namespace":"ingress-b","exported_namespace":"shop","service":"cart"},"value":[1530000000,"30"]}]}}`))
+			return
+		}
+
+		switch {
+		case strings.Contains(query, nginxStatus):
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"service_namespace":"shop","service":"cart","status":"200"},"value":[1530000000,"36"]},
+				{"metric":{"service_namespace":"shop","service":"cart","status":"503"},"value":[1530000000,"4"]}]}}`))
+		case strings.Contains(query, nginx_REQUEST_DURATION_SUM):
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"service_namespace":"shop","service":"cart"},"value":[1530000000,"35"]}]}}`))
+		default:
+			w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+				{"metric":{"service_namespace":"shop","service":"cart"},"value":[1530000000,"40"]}]}}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+
+	g := NewNginxIngressEntityGetter("nginx.vapp.metric", "3m", DefaultLatencyOption())
+	result, err := g.GetEntityMetric(client)
+	if err != nil || len(result) != 1 {
+		t.Fatalf("Failed to get entity metrics: %+v, %v", result, err)
+	}
+
+	e := result[0]
+	if e.UID != "shop/cart" || e.Metrics[inter.TpsType] != 40 || e.Metrics[inter.LatencyType] != 35 {
+		t.Errorf("Wrong entity: %+v", e)
+	}
+	if e.Attributes[inter.RequestRate] != 40 || e.Attributes[inter.ErrorRate5xx] != 0.1 {
+		t.Errorf("Wrong attributes: %+v", e.Attributes)
+	}
+}
@@ -0,0 +1,93 @@
+package addon
+
+import (
+	"fmt"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+)
+
+// the metrics of node_exporter
+const (
+	node_CPU_SECONDS       = "node_cpu_seconds_total"
+	node_CPU_FREQUENCY_MAX = "node_cpu_scaling_frequency_max_hertz"
+	node_MEM_TOTAL         = "node_memory_MemTotal_bytes"
+	node_MEM_AVAILABLE     = "node_memory_MemAvailable_bytes"
+	node_NET_RECEIVE       = "node_network_receive_bytes_total"
+	node_NET_TRANSMIT      = "node_network_transmit_bytes_total"
+	node_DISK_READ         = "node_disk_read_bytes_total"
+	node_DISK_WRITTEN      = "node_disk_written_bytes_total"
+
+	// the CPU time not spent in idle, or waiting for IO or the hypervisor
+	nodeCPUBusySelector = `mode!~"idle|iowait|steal"`
+	nodeCPUIdleSelector = `mode="idle"`
+	nodeNetSelector     = `device!="lo"`
+
+	// the CPU frequency in MHz, if the cpufreq collector is not enabled
+	default_CPU_Frequency = 2000
+
+	default_Node_Port = 9100
+
+	// the hosts are identified by "vm/<ip>", as the applications on the hosts may be identified by the same IP
+	nodeUIDPrefix = "vm" + uidSeparator
+)
+
+// NodeEntityGetter builds the hosts (VirtualMachine) from the metrics of node_exporter, identified by "vm/<instance IP>",
+// to be stitched by the IP with the VMs discovered by the hypervisor probes:
+// VCPU in MHz, with the capacity of all the cores; VMEM in KB, with the capacity of the total memory;
+// and the network and disk throughputs in KB/s.
+type NodeEntityGetter struct {
+	instanceEntityGetter
+}
+
+// ensure NodeEntityGetter implement the requisite interfaces
+var _ alligator.EntityMetricGetter = &NodeEntityGetter{}
+
+func NewNodeEntityGetter(name, du string) *NodeEntityGetter {
+	return &NodeEntityGetter{
+		instanceEntityGetter{
+			name:        name,
+			category:    NodeGetterCategory,
+			entityType:  inter.VMEntity,
+			defaultPort: default_Node_Port,
+			uidPrefix:   nodeUIDPrefix,
+			queries: []*instanceQuery{
+				{ctype: inter.VCPUType, query: getNodeCPUExp(getNodeBusyCoresExp(du)), required: true},
+				{ctype: inter.VCPUType, query: getNodeCPUExp(getNodeCoresExp()), capacity: true},
+				{ctype: inter.VMemType, query: getNodeMemUsedExp()},
+				{ctype: inter.VMemType, query: sumByInstance(node_MEM_TOTAL) + " / 1024", capacity: true},
+				{ctype: inter.NetThroughputType, query: getNodeThroughputExp(node_NET_RECEIVE, node_NET_TRANSMIT, nodeNetSelector, du)},
+				{ctype: inter.IOThroughputType, query: getNodeThroughputExp(node_DISK_READ, node_DISK_WRITTEN, "", du)},
+			},
+		},
+	}
+}
+
+// the busy cores: the CPU seconds per second, not in idle
+func getNodeBusyCoresExp(du string) string {
+	return fmt.Sprintf("sum by (%v) (rate(%v{%v}[%v]))", instanceLabel, node_CPU_SECONDS, nodeCPUBusySelector, du)
+}
+
+// the number of the cores: every core has its idle CPU seconds
+func getNodeCoresExp() string {
+	return fmt.Sprintf("count by (%v) (%v{%v})", instanceLabel, node_CPU_SECONDS, nodeCPUIdleSelector)
+}
+
+// the CPU in MHz: the cores multiplied by the max frequency of the cores, or the default frequency if not exported
+func getNodeCPUExp(cores string) string {
+	frequency := fmt.Sprintf("max by (%v) (%v) / 1000000 or %v * 0 + %v",
+		instanceLabel, node_CPU_FREQUENCY_MAX, getNodeCoresExp(), default_CPU_Frequency)
+	return fmt.Sprintf("%v * (%v)", cores, frequency)
+}
+
+// the used memory in KB: the memory not available for starting new applications
+func getNodeMemUsedExp() string {
+	return fmt.Sprintf("(%v - %v) / 1024", sumByInstance(node_MEM_TOTAL), sumByInstance(node_MEM_AVAILABLE))
+}
+
+// the received and sent, or read and written, KB per second
+func getNodeThroughputExp(in, out, selector, du string) string {
+	rate := func(metric string) string {
+		return fmt.Sprintf("sum by (%v) (rate(%v{%v}[%v]))", instanceLabel, metric, selector, du)
+	}
+	return fmt.Sprintf("(%v + %v) / 1024", rate(in), rate(out))
+}
@@ -0,0 +1,87 @@
+package addon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+func TestNodeEntityGetter_GetEntityMetric(t *testing.T) {
+	e := getInstanceEntity(t, NodeGetterCategory, "10.10.0.5:9100", []metricValue{
+		{nodeCPUBusySelector, "3000"},
+		{node_CPU_SECONDS, "8000"},
+		{node_MEM_AVAILABLE, "4194304"},
+		{node_MEM_TOTAL, "16777216"},
+		{node_NET_RECEIVE, "1250"},
+		{node_DISK_READ, "512"},
+	})
+
+	if e.UID != "vm/10.10.0.5" || e.Type != inter.VMEntity || e.Labels[inter.IP] != "10.10.0.5" {
+		t.Errorf("Wrong entity: %+v", e)
+	}
+	if e.Metrics[inter.VCPUType] != 3000 || e.Capacities[inter.VCPUType] != 8000 {
+		t.Errorf("Wrong VCPU: %+v, %+v", e.Metrics, e.Capacities)
+	}
+	if e.Metrics[inter.VMemType] != 4194304 || e.Capacities[inter.VMemType] != 16777216 {
+		t.Errorf("Wrong VMEM: %+v, %+v", e.Metrics, e.Capacities)
+	}
+	if e.Metrics[inter.NetThroughputType] != 1250 || e.Metrics[inter.IOThroughputType] != 512 {
+		t.Errorf("Wrong throughputs: %+v", e.Metrics)
+	}
+}
+
+func TestGetNodeCPUExp(t *testing.T) {
+	exp := getNodeCPUExp(getNodeCoresExp())
+	expected := `count by (instance) (node_cpu_seconds_total{mode="idle"}) * ` +
+		`(max by (instance) (node_cpu_scaling_frequency_max_hertz) / 1000000 or count by (instance) (node_cpu_seconds_total{mode="idle"}) * 0 + 2000)`
+	if exp != expected {
+		t.Errorf("Wrong query: %v", exp)
+	}
+}
+
+// the host and the MySQL server on it are scraped with the same IP: both of them are reported
+func TestNodeEntityGetter_WithAppOnSameIP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		instance := "10.10.0.5:9100"
+		if strings.Contains(query, "mysql_") {
+			instance = "10.10.0.5:9104"
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"instance":"` + instance + `"},"value":[1530000000,"10"]}]}}`))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+
+	c := alligator.NewAlligator(alligator.NewSource("", client))
+	c.AddGetter(NewNodeEntityGetter("node.vm.metric", "3m"))
+	c.AddGetter(NewMySQLEntityGetter("mysql.app.metric", "3m"))
+
+	resp, err := c.GetEntityMetrics()
+	if err != nil || len(resp.Data) != 2 {
+		t.Fatalf("Failed to get entity metrics: %+v, %v", resp, err)
+	}
+
+	types := make(map[string]string)
+	for _, e := range resp.Data {
+		types[e.UID] = e.Type.String()
+		if e.Labels[inter.IP] != "10.10.0.5" {
+			t.Errorf("Wrong IP of entity: %+v", e)
+		}
+	}
+	expected := map[string]string{"vm/10.10.0.5": inter.VMEntity.String(), "10.10.0.5": inter.AppEntity.String()}
+	for uid, etype := range expected {
+		if types[uid] != etype {
+			t.Errorf("Wrong entities: %v", types)
+		}
+	}
+}
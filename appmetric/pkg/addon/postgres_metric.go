@@ -0,0 +1,65 @@
+package addon
+
+import (
+	"fmt"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+)
+
+// the metrics of postgres_exporter
+const (
+	pg_XACT_COMMIT      = "pg_stat_database_xact_commit"
+	pg_XACT_ROLLBACK    = "pg_stat_database_xact_rollback"
+	pg_NUMBACKENDS      = "pg_stat_database_numbackends"
+	pg_MAX_CONNECTIONS  = "pg_settings_max_connections"
+	pg_BLKS_HIT         = "pg_stat_database_blks_hit"
+	pg_BLKS_READ        = "pg_stat_database_blks_read"
+	pg_STATEMENTS_TIME  = "pg_stat_statements_seconds_total"
+	pg_STATEMENTS_CALLS = "pg_stat_statements_calls_total"
+
+	default_Postgres_Port = 9187
+)
+
+// PostgresEntityGetter builds the PostgreSQL servers from the metrics of postgres_exporter:
+// TPS from the committed and rolled back transactions, the connections, the cache hit ratio,
+// and the statement latency if the pg_stat_statements collector is enabled.
+type PostgresEntityGetter struct {
+	instanceEntityGetter
+}
+
+// ensure PostgresEntityGetter implement the requisite interfaces
+var _ alligator.EntityMetricGetter = &PostgresEntityGetter{}
+
+func NewPostgresEntityGetter(name, du string) *PostgresEntityGetter {
+	return &PostgresEntityGetter{
+		instanceEntityGetter{
+			name:        name,
+			category:    PostgresGetterCategory,
+			entityType:  inter.AppEntity,
+			defaultPort: default_Postgres_Port,
+			queries: []*instanceQuery{
+				{ctype: inter.TpsType, query: getPostgresTPSExp(du), required: true},
+				{ctype: inter.LatencyType, query: getPostgresLatencyExp(du)},
+				{ctype: inter.ConnectionType, query: sumByInstance(pg_NUMBACKENDS)},
+				{ctype: inter.ConnectionType, query: fmt.Sprintf("max by (%v) (%v)", instanceLabel, pg_MAX_CONNECTIONS), capacity: true},
+				{ctype: inter.DBCacheHitRateType, query: getPostgresCacheHitExp(du)},
+			},
+		},
+	}
+}
+
+// the transactions of all the databases, committed or rolled back
+func getPostgresTPSExp(du string) string {
+	return fmt.Sprintf("%v + %v", sumRateByInstance(pg_XACT_COMMIT, du), sumRateByInstance(pg_XACT_ROLLBACK, du))
+}
+
+// the mean latency of the statements in milliseconds
+func getPostgresLatencyExp(du string) string {
+	return fmt.Sprintf("1000*%v / %v", sumRateByInstance(pg_STATEMENTS_TIME, du), sumRateByInstance(pg_STATEMENTS_CALLS, du))
+}
+
+// the percentage of the blocks read from the shared buffers
+func getPostgresCacheHitExp(du string) string {
+	hit := sumRateByInstance(pg_BLKS_HIT, du)
+	return fmt.Sprintf("100*%v / (%v + %v)", hit, hit, sumRateByInstance(pg_BLKS_READ, du))
+}
@@ -0,0 +1,38 @@
+package addon
+
+import (
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+)
+
+func TestPostgresEntityGetter_GetEntityMetric(t *testing.T) {
+	e := getInstanceEntity(t, PostgresGetterCategory, "10.2.5.18:9187", []metricValue{
+		{pg_XACT_COMMIT, "42"},
+		{pg_STATEMENTS_TIME, "3.5"},
+		{pg_NUMBACKENDS, "20"},
+		{pg_MAX_CONNECTIONS, "100"},
+		{pg_BLKS_HIT, "97"},
+	})
+
+	if e.UID != "10.2.5.18" {
+		t.Errorf("Wrong entity: %+v", e)
+	}
+	if e.Metrics[inter.TpsType] != 42 || e.Metrics[inter.LatencyType] != 3.5 || e.Metrics[inter.DBCacheHitRateType] != 97 {
+		t.Errorf("Wrong metrics: %+v", e.Metrics)
+	}
+	if e.Metrics[inter.ConnectionType] != 20 || e.Capacities[inter.ConnectionType] != 100 {
+		t.Errorf("Wrong connections: %+v, %+v", e.Metrics, e.Capacities)
+	}
+}
+
+func TestPostgresEntityGetter_NoStatements(t *testing.T) {
+	// the latency is optional, as pg_stat_statements may not be enabled
+	e := getInstanceEntity(t, PostgresGetterCategory, "10.2.5.18:9187", []metricValue{
+		{pg_XACT_COMMIT, "42"},
+	})
+
+	if _, ok := e.Metrics[inter.LatencyType]; ok || e.Metrics[inter.TpsType] != 42 {
+		t.Errorf("Wrong metrics: %+v", e.Metrics)
+	}
+}
@@ -18,6 +18,45 @@ const (
 	default_Redis_Port = 6379
 )
 
+// the metrics of redis_exporter for the latency and the database commodities
+const (
+	redis_COMMANDS_TOTAL          = "redis_commands_total"
+	redis_COMMANDS_DURATION_TOTAL = "redis_commands_duration_seconds_total"
+	redis_MEMORY_USED             = "redis_memory_used_bytes"
+	redis_MEMORY_MAX              = "redis_memory_max_bytes"
+	redis_TOTAL_SYSTEM_MEMORY     = "redis_total_system_memory_bytes"
+	redis_CONNECTED_CLIENTS       = "redis_connected_clients"
+	redis_CONFIG_MAXCLIENTS       = "redis_config_maxclients"
+	redis_KEYSPACE_HITS           = "redis_keyspace_hits_total"
+	redis_KEYSPACE_MISSES         = "redis_keyspace_misses_total"
+	redisAddrLabel                = "addr"
+)
+
+// QueryTypes of Redis
+const (
+	redisTPS = iota
+	redisLatency
+	redisMemory
+	redisMemoryCapacity
+	redisConnection
+	redisConnectionCapacity
+	redisCacheHitRate
+)
+
+// the optional metrics of Redis, which don't fail the getter
+var redisOptionalQueries = []struct {
+	qtype    int
+	ctype    proto.CommodityDTO_CommodityType
+	capacity bool
+}{
+	{redisLatency, inter.LatencyType, false},
+	{redisMemory, inter.DBMemType, false},
+	{redisMemoryCapacity, inter.DBMemType, true},
+	{redisConnection, inter.ConnectionType, false},
+	{redisConnectionCapacity, inter.ConnectionType, true},
+	{redisCacheHitRate, inter.DBCacheHitRateType, false},
+}
+
 type RedisEntityGetter struct {
 	name  string
 	query *redisQuery
@@ -41,28 +80,31 @@ func (r *RedisEntityGetter) Category() string {
 	return "Redis"
 }
 
-func (r *RedisEntityGetter) GetEntityMetric(client *xfire.RestClient) ([]*inter.EntityMetric, error) {
+func (r *RedisEntityGetter) GetEntityMetric(client xfire.MetricClient) ([]*inter.EntityMetric, error) {
 	result := []*inter.EntityMetric{}
 	midResult := make(map[string]*inter.EntityMetric)
+	// the getter may run against several sources concurrently
+	query := *r.query
 
 	//1. get TPS data
-	r.query.SetQueryType(false)
-	tpsDat, err := client.GetMetrics(r.query)
+	query.SetQueryType(redisTPS)
+	tpsDat, err := client.GetMetrics(&query)
 	if err != nil {
 		glog.Errorf("Failed to get Redis TPS metrics: %v", err)
 		return result, err
 	} else {
-		r.addEntity(tpsDat, midResult, inter.TpsType)
+		r.addEntity(tpsDat, midResult, inter.TpsType, false)
 	}
 
-	//2. get Latency data
-	r.query.SetQueryType(true)
-	latencyDat, err := client.GetMetrics(r.query)
-	if err != nil {
-		glog.Errorf("Failed to get Redis Latency metrics: %v", err)
-		//return result, err
-	} else {
-		r.addEntity(latencyDat, midResult, inter.LatencyType)
+	//2. get Latency, memory, connection and cache hit rate data
+	for _, q := range redisOptionalQueries {
+		query.SetQueryType(q.qtype)
+		dat, err := client.GetMetrics(&query)
+		if err != nil {
+			glog.Errorf("Failed to get Redis %v metrics: %v", q.ctype, err)
+			continue
+		}
+		r.addEntity(dat, midResult, q.ctype, q.capacity)
 	}
 
 	//3. reform map to list
@@ -73,9 +115,9 @@ func (r *RedisEntityGetter) GetEntityMetric(client *xfire.RestClient) ([]*inter.
 	return result, nil
 }
 
-// key should be inter.TPS or inter.Latency
-func (r *RedisEntityGetter) addEntity(mdat []xfire.MetricData, result map[string]*inter.EntityMetric, key proto.CommodityDTO_CommodityType) error {
-	addrName := "addr"
+// the value is set as the capacity of the commodity if capacity is true
+func (r *RedisEntityGetter) addEntity(mdat []xfire.MetricData, result map[string]*inter.EntityMetric, key proto.CommodityDTO_CommodityType, capacity bool) error {
+	addrName := redisAddrLabel
 
 	for _, dat := range mdat {
 		metric, ok := dat.(*xfire.BasicMetricData)
@@ -107,16 +149,17 @@ func (r *RedisEntityGetter) addEntity(mdat []xfire.MetricData, result map[string
 			result[ip] = entity
 		}
 
-		entity.SetMetric(key, metric.GetValue())
+		if capacity {
+			entity.SetCapacity(key, metric.GetValue())
+		} else {
+			entity.SetMetric(key, metric.GetValue())
+		}
 	}
 
 	return nil
 }
 
 //------------------ Get and Parse the metrics ---------------
-// QueryTypes
-//    0: TPS
-//    1: Latency
 type redisQuery struct {
 	qtype    int
 	du       string // summary sample duration
@@ -130,17 +173,18 @@ func newRedisQuery(du string) *redisQuery {
 		queryMap: make(map[int]string),
 	}
 
-	q.queryMap[0] = q.getRPSExp()
-	q.queryMap[1] = q.getLatencyExp()
+	q.queryMap[redisTPS] = q.getRPSExp()
+	q.queryMap[redisLatency] = q.getLatencyExp()
+	q.queryMap[redisMemory] = q.getMemoryExp()
+	q.queryMap[redisMemoryCapacity] = q.getMemoryCapacityExp()
+	q.queryMap[redisConnection] = redis_CONNECTED_CLIENTS
+	q.queryMap[redisConnectionCapacity] = redis_CONFIG_MAXCLIENTS
+	q.queryMap[redisCacheHitRate] = q.getCacheHitRateExp()
 	return q
 }
 
-func (q *redisQuery) SetQueryType(isLatency bool) {
-	if isLatency {
-		q.qtype = 1
-	} else {
-		q.qtype = 0
-	}
+func (q *redisQuery) SetQueryType(qtype int) {
+	q.qtype = qtype
 }
 
 func (q *redisQuery) GetQuery() string {
@@ -154,9 +198,30 @@ func (q *redisQuery) getRPSExp() string {
 	return result
 }
 
+// the mean latency of the commands in milliseconds:
+// 1000*sum by (addr) (rate(redis_commands_duration_seconds_total[3m])) / sum by (addr) (rate(redis_commands_total[3m]))
 func (q *redisQuery) getLatencyExp() string {
-	glog.Errorf("Redis has no Latency metric.")
-	return ""
+	result := fmt.Sprintf("1000*sum by (%v) (rate(%v[%v])) / sum by (%v) (rate(%v[%v]))",
+		redisAddrLabel, redis_COMMANDS_DURATION_TOTAL, q.du,
+		redisAddrLabel, redis_COMMANDS_TOTAL, q.du)
+	glog.V(3).Infof("Redis Latency: %v", result)
+	return result
+}
+
+// the used memory in KB
+func (q *redisQuery) getMemoryExp() string {
+	return fmt.Sprintf("%v/1024", redis_MEMORY_USED)
+}
+
+// the max memory in KB; the memory of the system if maxmemory is not set
+func (q *redisQuery) getMemoryCapacityExp() string {
+	return fmt.Sprintf("(%v > 0 or %v)/1024", redis_MEMORY_MAX, redis_TOTAL_SYSTEM_MEMORY)
+}
+
+// the percentage of the key lookups hitting the cache
+func (q *redisQuery) getCacheHitRateExp() string {
+	return fmt.Sprintf("100*rate(%v[%v]) / (rate(%v[%v]) + rate(%v[%v]))",
+		redis_KEYSPACE_HITS, q.du, redis_KEYSPACE_HITS, q.du, redis_KEYSPACE_MISSES, q.du)
 }
 
 func (q *redisQuery) Parse(m *xfire.RawMetric) (xfire.MetricData, error) {
@@ -0,0 +1,65 @@
+package addon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	xfire "github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+func TestRedisEntityGetter_GetEntityMetric(t *testing.T) {
+	values := map[string]string{
+		redis_COMMANDS_DURATION_TOTAL: "0.25",
+		redis_MEMORY_MAX:              "1048576",
+		redis_MEMORY_USED:             "2048",
+		redis_CONFIG_MAXCLIENTS:       "10000",
+		redis_CONNECTED_CLIENTS:       "12",
+		redis_KEYSPACE_HITS:           "92.5",
+		redis_OPS_TOTAL:               "300",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		value := ""
+		// the capacity queries contain the names of the used ones, so they are checked first
+		for _, name := range []string{redis_COMMANDS_DURATION_TOTAL, redis_MEMORY_MAX, redis_MEMORY_USED,
+			redis_CONFIG_MAXCLIENTS, redis_CONNECTED_CLIENTS, redis_KEYSPACE_HITS, redis_OPS_TOTAL} {
+			if strings.Contains(query, name) {
+				value = values[name]
+				break
+			}
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[
+			{"metric":{"addr":"10.2.3.31:6379"},"value":[1530000000,"` + value + `"]}]}}`))
+	}))
+	defer server.Close()
+
+	client, err := xfire.NewRestClient(server.URL)
+	if err != nil {
+		t.Errorf("Failed to create rest client: %v", err)
+		return
+	}
+
+	result, err := NewRedisEntityGetter("redis.app.metric", "3m").GetEntityMetric(client)
+	if err != nil || len(result) != 1 {
+		t.Errorf("Failed to get entity metrics: %+v, %v", result, err)
+		return
+	}
+
+	e := result[0]
+	if e.UID != "10.2.3.31" || e.Metrics[inter.TpsType] != 300 || e.Metrics[inter.LatencyType] != 0.25 {
+		t.Errorf("Wrong entity: %+v", e)
+	}
+	if e.Metrics[inter.DBMemType] != 2048 || e.Capacities[inter.DBMemType] != 1048576 {
+		t.Errorf("Wrong memory: %+v, %+v", e.Metrics, e.Capacities)
+	}
+	if e.Metrics[inter.ConnectionType] != 12 || e.Capacities[inter.ConnectionType] != 10000 {
+		t.Errorf("Wrong connections: %+v, %+v", e.Metrics, e.Capacities)
+	}
+	if e.Metrics[inter.DBCacheHitRateType] != 92.5 {
+		t.Errorf("Wrong cache hit rate: %+v", e.Metrics)
+	}
+}
@@ -1,27 +1,114 @@
 package alligator
 
 import (
+	"context"
+	"fmt"
 	"github.com/golang/glog"
+	"sync"
+	"time"
 
 	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
 	"github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
 )
 
+const (
+	DefaultGetterTimeout = time.Duration(30 * time.Second)
+
+	uidSeparator = "/"
+)
+
 type EntityMetricGetter interface {
-	GetEntityMetric(client *prometheus.RestClient) ([]*inter.EntityMetric, error)
+	GetEntityMetric(client prometheus.MetricClient) ([]*inter.EntityMetric, error)
 	Name() string
+	Category() string
+}
+
+// AttachmentGetter is implemented by the getters which report the attributes of the entities of other getters
+type AttachmentGetter interface {
+	GetAttachments(client prometheus.MetricClient) ([]*inter.Attachment, error)
+}
+
+// EdgeGetter is implemented by the getters which also discover the dependencies between the entities
+type EdgeGetter interface {
+	GetEdges(client prometheus.MetricClient) ([]*inter.Edge, error)
+}
+
+// Source is a prometheus server, or a group of HA replicas, to run the getters against.
+// The label marks the cluster or scope of the source:
+// if it is not empty, the UIDs of the entities from the source are prefixed by it.
+type Source struct {
+	Label  string
+	Client prometheus.MetricClient
+}
+
+func NewSource(label string, client prometheus.MetricClient) *Source {
+	return &Source{
+		Label:  label,
+		Client: client,
+	}
 }
 
 // Alligator: aggregates several kinds of Entity metric getters
 type Alligator struct {
-	pclient *prometheus.RestClient
+	sources []*Source
 	Getters map[string]EntityMetricGetter
+
+	// the deadline of each getter
+	timeout  time.Duration
+	timeouts map[string]time.Duration
+
+	// a getter may still be running after its deadline; the lock avoids running it concurrently
+	// against the same source.
+	locks     map[string]*sync.Mutex
+	locksLock sync.Mutex
+
+	// merge the metrics of the same entity from different getters
+	mergePolicy *MergePolicy
+}
+
+// the result of one getter
+type getterResult struct {
+	name        string
+	category    string
+	source      string
+	metrics     []*inter.EntityMetric
+	edges       []*inter.Edge
+	attachments []*inter.Attachment
+	err         error
+	duration    time.Duration
+	warnings    []string
 }
 
-func NewAlligator(pclient *prometheus.RestClient) *Alligator {
+func (r *getterResult) status() *inter.GetterStatus {
+	s := &inter.GetterStatus{
+		Name:        r.name,
+		Category:    r.category,
+		Source:      r.source,
+		Success:     r.err == nil,
+		EntityCount: len(r.metrics),
+		DurationMs:  float64(r.duration) / float64(time.Millisecond),
+	}
+
+	if len(r.warnings) > 0 {
+		s.Warnings = r.warnings
+	}
+
+	if r.err != nil {
+		s.Error = r.err.Error()
+		s.EntityCount = 0
+	}
+	return s
+}
+
+func NewAlligator(sources ...*Source) *Alligator {
 	result := &Alligator{
-		pclient: pclient,
-		Getters: make(map[string]EntityMetricGetter),
+		sources:  sources,
+		Getters:  make(map[string]EntityMetricGetter),
+		timeout:  DefaultGetterTimeout,
+		timeouts: make(map[string]time.Duration),
+		locks:    make(map[string]*sync.Mutex),
+
+		mergePolicy: DefaultMergePolicy(),
 	}
 
 	return result
@@ -38,17 +125,216 @@ func (c *Alligator) AddGetter(getter EntityMetricGetter) bool {
 	return true
 }
 
-func (c *Alligator) GetEntityMetrics() ([]*inter.EntityMetric, error) {
-	result := []*inter.EntityMetric{}
-	for _, getter := range c.Getters {
-		dat, err := getter.GetEntityMetric(c.pclient)
-		if err != nil {
-			glog.Errorf("Failed to get entity metrics: %v", err)
+// getLock returns the lock of the getter on the source
+func (c *Alligator) getLock(name, source string) *sync.Mutex {
+	c.locksLock.Lock()
+	defer c.locksLock.Unlock()
+
+	key := name + "@" + source
+	lock, ok := c.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		c.locks[key] = lock
+	}
+	return lock
+}
+
+// SetTimeout sets the default deadline of the getters
+func (c *Alligator) SetTimeout(timeout time.Duration) {
+	c.timeout = timeout
+}
+
+// SetGetterTimeout overrides the deadline of the named getter
+func (c *Alligator) SetGetterTimeout(name string, timeout time.Duration) {
+	c.timeouts[name] = timeout
+}
+
+// SetMergePolicy sets how the metrics of the same entity from different getters are merged
+func (c *Alligator) SetMergePolicy(policy *MergePolicy) {
+	c.mergePolicy = policy
+}
+
+func (c *Alligator) getTimeout(name string) time.Duration {
+	if timeout, ok := c.timeouts[name]; ok && timeout > 0 {
+		return timeout
+	}
+	return c.timeout
+}
+
+// GetEntityMetrics runs all the getters against all the sources in parallel;
+// a getter that fails or misses its deadline is skipped, and the results of the others are returned.
+// The status of every getter on every source is reported in the response.
+// The metrics of the same entity from different getters are merged by the merge policy.
+func (c *Alligator) GetEntityMetrics() (*inter.MetricResponse, error) {
+	resp := inter.NewMetricResponse()
+
+	total := len(c.Getters) * len(c.sources)
+	results := make(chan *getterResult, total)
+	for name, getter := range c.Getters {
+		for _, source := range c.sources {
+			go func(name string, getter EntityMetricGetter, source *Source) {
+				results <- c.runGetter(name, getter, source)
+			}(name, getter, source)
+		}
+	}
+
+	failed := 0
+	succeeded := []*getterResult{}
+	for i := 0; i < total; i++ {
+		r := <-results
+		resp.AddGetterStatus(r.status())
+		if r.err != nil {
+			glog.Errorf("Failed to get entity metrics from %v(source=%v): %v", r.name, r.source, r.err)
+			failed++
 			continue
 		}
 
-		result = append(result, dat...)
+		succeeded = append(succeeded, r)
+	}
+
+	resp.Data, resp.Conflicts = c.mergePolicy.Merge(succeeded)
+	resp.Edges = c.mergePolicy.MergeEdges(succeeded)
+	attach(resp.Data, succeeded)
+
+	if failed > 0 {
+		resp.SetStatus(0, fmt.Sprintf("%d of %d getters failed", failed, total))
+	} else {
+		resp.SetStatus(0, "Success")
+	}
+
+	return resp, nil
+}
+
+// runGetter runs the getter against the source with its deadline
+func (c *Alligator) runGetter(name string, getter EntityMetricGetter, source *Source) *getterResult {
+	timeout := c.getTimeout(name)
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// collect the warnings, e.g., partial responses, of the queries
+	collector := prometheus.NewWarningCollector()
+	ctx = prometheus.WithWarningCollector(ctx, collector)
+
+	done := make(chan *getterResult, 1)
+	go func() {
+		lock := c.getLock(name, source.Label)
+		lock.Lock()
+		defer lock.Unlock()
+
+		// the deadline may have been missed while waiting for the lock
+		if ctx.Err() != nil {
+			return
+		}
+
+		client := source.Client.WithContext(ctx)
+		metrics, err := getter.GetEntityMetric(client)
+		if err == nil {
+			source.namespace(metrics)
+		}
+
+		// the edges are optional: a failure is reported as a warning of the getter
+		var edges []*inter.Edge
+		if eg, ok := getter.(EdgeGetter); ok && err == nil {
+			var eerr error
+			if edges, eerr = eg.GetEdges(client); eerr != nil {
+				glog.Warningf("Failed to get edges from %v(source=%v): %v", name, source.Label, eerr)
+				collector.Add(name, []string{fmt.Sprintf("failed to get edges: %v", eerr)})
+			} else {
+				source.namespaceEdges(edges)
+			}
+		}
+
+		// the attachments are optional as well
+		var attachments []*inter.Attachment
+		if ag, ok := getter.(AttachmentGetter); ok && err == nil {
+			var aerr error
+			if attachments, aerr = ag.GetAttachments(client); aerr != nil {
+				glog.Warningf("Failed to get attachments from %v(source=%v): %v", name, source.Label, aerr)
+				collector.Add(name, []string{fmt.Sprintf("failed to get attachments: %v", aerr)})
+			}
+		}
+
+		done <- &getterResult{
+			name:        name,
+			category:    getter.Category(),
+			source:      source.Label,
+			metrics:     metrics,
+			edges:       edges,
+			attachments: attachments,
+			err:         err,
+			duration:    time.Since(start),
+			warnings:    collector.Warnings(),
+		}
+	}()
+
+	select {
+	case r := <-done:
+		return r
+	case <-ctx.Done():
+		return &getterResult{
+			name:     name,
+			category: getter.Category(),
+			source:   source.Label,
+			err:      fmt.Errorf("getter %v did not finish in %v", name, timeout),
+			duration: time.Since(start),
+		}
+	}
+}
+
+// Health returns the health of the prometheus servers of all the sources
+func (c *Alligator) Health() []*prometheus.EndpointHealth {
+	result := []*prometheus.EndpointHealth{}
+	for _, source := range c.sources {
+		for _, h := range source.Client.Health() {
+			h.Source = source.Label
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+// namespace prefixes the UIDs of the entities by the label of the source,
+// so the entities from different clusters won't collide.
+func (s *Source) namespace(metrics []*inter.EntityMetric) {
+	if len(s.Label) < 1 {
+		return
 	}
 
-	return result, nil
+	for _, m := range metrics {
+		m.UID = s.Label + uidSeparator + m.UID
+		m.SetLabel(inter.Source, s.Label)
+	}
+}
+
+// namespaceEdges prefixes the UIDs of the ends of the edges by the label of the source
+func (s *Source) namespaceEdges(edges []*inter.Edge) {
+	if len(s.Label) < 1 {
+		return
+	}
+
+	for _, e := range edges {
+		e.Caller = s.Label + uidSeparator + e.Caller
+		e.Callee = s.Label + uidSeparator + e.Callee
+	}
+}
+
+// attach adds the attributes of the attachments to the entities of the same source with the matched label;
+// the values of the same attribute from several attachments are summed up.
+func attach(entities []*inter.EntityMetric, results []*getterResult) {
+	for _, r := range results {
+		for _, a := range r.attachments {
+			matched := 0
+			for _, e := range entities {
+				if e.Labels[inter.Source] != r.source || e.Labels[a.Label] != a.Value {
+					continue
+				}
+				matched++
+				for k, v := range a.Attributes {
+					e.SetAttribute(k, e.Attributes[k]+v)
+				}
+			}
+			glog.V(4).Infof("Attachment %v=%v from %v is attached to %d entities", a.Label, a.Value, r.name, matched)
+		}
+	}
 }
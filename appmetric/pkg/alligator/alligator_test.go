@@ -0,0 +1,230 @@
+package alligator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+type mockGetter struct {
+	name  string
+	delay time.Duration
+	err   error
+}
+
+func (m *mockGetter) Name() string {
+	return m.name
+}
+
+func (m *mockGetter) Category() string {
+	return "Mock"
+}
+
+func (m *mockGetter) GetEntityMetric(client prometheus.MetricClient) ([]*inter.EntityMetric, error) {
+	time.Sleep(m.delay)
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return []*inter.EntityMetric{inter.NewEntityMetric(m.name, inter.AppEntity)}, nil
+}
+
+func newTestAlligator(t *testing.T, getters ...EntityMetricGetter) *Alligator {
+	pclient, err := prometheus.NewRestClient("http://127.0.0.1:9090")
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+
+	c := NewAlligator(NewSource("", pclient))
+	for _, g := range getters {
+		c.AddGetter(g)
+	}
+	return c
+}
+
+func TestAlligator_GetEntityMetrics(t *testing.T) {
+	c := newTestAlligator(t,
+		&mockGetter{name: "a", delay: 100 * time.Millisecond},
+		&mockGetter{name: "b", delay: 100 * time.Millisecond},
+		&mockGetter{name: "c", err: fmt.Errorf("mocked failure")},
+	)
+
+	start := time.Now()
+	resp, err := c.GetEntityMetrics()
+	if err != nil {
+		t.Errorf("Failed to get entity metrics: %v", err)
+		return
+	}
+
+	if len(resp.Data) != 2 {
+		t.Errorf("Wrong number of entities: %d Vs. 2", len(resp.Data))
+	}
+
+	if elapsed := time.Since(start); elapsed > 180*time.Millisecond {
+		t.Errorf("Getters are not run in parallel: %v", elapsed)
+	}
+}
+
+func TestAlligator_GetEntityMetrics_Timeout(t *testing.T) {
+	c := newTestAlligator(t,
+		&mockGetter{name: "fast"},
+		&mockGetter{name: "slow", delay: time.Second},
+		&mockGetter{name: "slow2", delay: 200 * time.Millisecond},
+	)
+	c.SetTimeout(100 * time.Millisecond)
+	c.SetGetterTimeout("slow2", time.Second)
+
+	start := time.Now()
+	resp, _ := c.GetEntityMetrics()
+	elapsed := time.Since(start)
+
+	if len(resp.Data) != 2 {
+		t.Errorf("Wrong number of entities: %d Vs. 2", len(resp.Data))
+	}
+
+	for _, e := range resp.Data {
+		if e.UID == "slow" {
+			t.Errorf("The result of getter missing its deadline is returned")
+		}
+	}
+
+	if elapsed > 500*time.Millisecond {
+		t.Errorf("The deadline of getter is not respected: %v", elapsed)
+	}
+}
+
+func TestAlligator_GetEntityMetrics_Status(t *testing.T) {
+	c := newTestAlligator(t,
+		&mockGetter{name: "good"},
+		&mockGetter{name: "bad", err: fmt.Errorf("mocked failure")},
+		&mockGetter{name: "slow", delay: time.Second},
+	)
+	c.SetTimeout(100 * time.Millisecond)
+
+	resp, _ := c.GetEntityMetrics()
+	if resp.Status != 0 || len(resp.Getters) != 3 {
+		t.Errorf("Wrong response: %+v", resp)
+		return
+	}
+
+	for _, s := range resp.Getters {
+		if s.Category != "Mock" {
+			t.Errorf("Wrong category of getter %v: %v", s.Name, s.Category)
+		}
+
+		switch s.Name {
+		case "good":
+			if !s.Success || s.EntityCount != 1 || len(s.Error) > 0 {
+				t.Errorf("Wrong status of getter %v: %+v", s.Name, s)
+			}
+		default:
+			if s.Success || s.EntityCount != 0 || len(s.Error) < 1 {
+				t.Errorf("Wrong status of getter %v: %+v", s.Name, s)
+			}
+		}
+	}
+
+	if len(resp.FailedGetters()) != 2 {
+		t.Errorf("Wrong number of failed getters: %d Vs. 2", len(resp.FailedGetters()))
+	}
+}
+
+func TestAlligator_GetEntityMetrics_Sources(t *testing.T) {
+	c := NewAlligator(
+		NewSource("cluster1", prometheus.NewReplicaClient(nil)),
+		NewSource("cluster2", prometheus.NewReplicaClient(nil)),
+	)
+	c.AddGetter(&mockGetter{name: "a"})
+
+	resp, _ := c.GetEntityMetrics()
+	if len(resp.Data) != 2 || len(resp.Getters) != 2 {
+		t.Errorf("Wrong response: %+v", resp)
+		return
+	}
+
+	uids := make(map[string]string)
+	for _, e := range resp.Data {
+		uids[e.UID] = e.Labels[inter.Source]
+	}
+
+	for _, source := range []string{"cluster1", "cluster2"} {
+		if uids[source+"/a"] != source {
+			t.Errorf("Entity of source %v is not namespaced: %+v", source, uids)
+		}
+	}
+}
+
+type mockEdgeGetter struct {
+	mockGetter
+	edges []*inter.Edge
+	err   error
+}
+
+func (m *mockEdgeGetter) GetEdges(client prometheus.MetricClient) ([]*inter.Edge, error) {
+	return m.edges, m.err
+}
+
+func TestAlligator_GetEntityMetrics_Edges(t *testing.T) {
+	c := NewAlligator(NewSource("cluster1", prometheus.NewReplicaClient(nil)))
+	c.AddGetter(&mockEdgeGetter{
+		mockGetter: mockGetter{name: "a"},
+		edges: []*inter.Edge{
+			inter.NewEdge("default/productpage", inter.VAppEntity, "default/reviews", inter.VAppEntity),
+		},
+	})
+	c.AddGetter(&mockEdgeGetter{
+		mockGetter: mockGetter{name: "b"},
+		err:        fmt.Errorf("mocked failure"),
+	})
+
+	resp, _ := c.GetEntityMetrics()
+	if len(resp.Data) != 2 || len(resp.Edges) != 1 {
+		t.Errorf("Wrong response: %+v", resp)
+		return
+	}
+
+	e := resp.Edges[0]
+	if e.Caller != "cluster1/default/productpage" || e.Callee != "cluster1/default/reviews" {
+		t.Errorf("Edge is not namespaced: %+v", e)
+	}
+
+	// the failure of the edges does not fail the getter
+	for _, s := range resp.Getters {
+		if !s.Success {
+			t.Errorf("Getter %v should not fail: %v", s.Name, s.Error)
+		}
+		if s.Name == "b" && len(s.Warnings) != 1 {
+			t.Errorf("Expected a warning of getter b: %v", s.Warnings)
+		}
+	}
+}
+
+func TestAttach(t *testing.T) {
+	pod1 := inter.NewEntityMetric("10.0.0.1", inter.AppEntity)
+	pod1.SetLabel("workload", "consumer")
+	pod2 := inter.NewEntityMetric("10.0.0.2", inter.AppEntity)
+	pod2.SetLabel("workload", "consumer")
+	pod2.SetLabel(inter.Source, "cluster2")
+	other := inter.NewEntityMetric("10.0.0.3", inter.AppEntity)
+	other.SetLabel("workload", "producer")
+
+	a1 := inter.NewAttachment("workload", "consumer")
+	a1.Attributes["consumer_lag"] = 10
+	a2 := inter.NewAttachment("workload", "consumer")
+	a2.Attributes["consumer_lag"] = 5
+
+	attach([]*inter.EntityMetric{pod1, pod2, other}, []*getterResult{
+		{name: "a", attachments: []*inter.Attachment{a1, a2}},
+	})
+
+	if pod1.Attributes["consumer_lag"] != 15 {
+		t.Errorf("Wrong attributes of the matched entity: %+v", pod1.Attributes)
+	}
+	// the entities of other sources, or other label values, are not matched
+	if len(pod2.Attributes) != 0 || len(other.Attributes) != 0 {
+		t.Errorf("Unmatched entities are attached: %+v, %+v", pod2.Attributes, other.Attributes)
+	}
+}
@@ -0,0 +1,213 @@
+package alligator
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sort"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// The policies to resolve the conflicting metrics of the same entity from different getters
+const (
+	// take the value from the getter with the highest priority
+	MergePrefer = "prefer"
+	MergeMax    = "max"
+	MergeSum    = "sum"
+)
+
+// MergePolicy decides how the metrics of the same entity (same UID) from different getters are merged
+type MergePolicy struct {
+	Method string
+	// names of the getters in the order of priority; the getters not listed are ordered by name.
+	Preferred []string
+}
+
+func NewMergePolicy(method string, preferred []string) (*MergePolicy, error) {
+	switch method {
+	case MergePrefer, MergeMax, MergeSum:
+	default:
+		return nil, fmt.Errorf("Unknown merge policy: %v", method)
+	}
+
+	return &MergePolicy{
+		Method:    method,
+		Preferred: preferred,
+	}, nil
+}
+
+func DefaultMergePolicy() *MergePolicy {
+	return &MergePolicy{Method: MergePrefer}
+}
+
+// a metric value of an entity reported by a getter
+type metricSource struct {
+	getter string
+	value  float64
+}
+
+// sortResults sorts the getter results by the priority of the getters
+func (p *MergePolicy) sortResults(results []*getterResult) {
+	rank := make(map[string]int)
+	for i, name := range p.Preferred {
+		if _, ok := rank[name]; !ok {
+			rank[name] = i
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		ri, iok := rank[results[i].name]
+		rj, jok := rank[results[j].name]
+		if iok && jok {
+			return ri < rj
+		}
+		if iok != jok {
+			return iok
+		}
+		return results[i].name < results[j].name
+	})
+}
+
+// Merge combines the labels and metrics of the entities with the same UID;
+// the metrics reported by several getters with different values are resolved by the policy, and reported as conflicts.
+func (p *MergePolicy) Merge(results []*getterResult) ([]*inter.EntityMetric, []*inter.MetricConflict) {
+	p.sortResults(results)
+
+	entities := []*inter.EntityMetric{}
+	merged := make(map[string]*inter.EntityMetric)
+	sources := make(map[string]map[proto.CommodityDTO_CommodityType][]*metricSource)
+
+	for _, r := range results {
+		for _, e := range r.metrics {
+			entity, exist := merged[e.UID]
+			if !exist {
+				entity = inter.NewEntityMetric(e.UID, e.Type)
+				merged[e.UID] = entity
+				sources[e.UID] = make(map[proto.CommodityDTO_CommodityType][]*metricSource)
+				entities = append(entities, entity)
+			}
+
+			if entity.Type != e.Type {
+				glog.Errorf("Entity %v from getter %v is dropped: type %v conflicts with %v",
+					e.UID, r.name, e.Type, entity.Type)
+				continue
+			}
+
+			// labels from the getter with higher priority are kept
+			for k, v := range e.Labels {
+				if _, ok := entity.Labels[k]; !ok {
+					entity.SetLabel(k, v)
+				}
+			}
+
+			for k, v := range e.Attributes {
+				if _, ok := entity.Attributes[k]; !ok {
+					entity.SetAttribute(k, v)
+				}
+			}
+
+			for ctype, v := range e.Capacities {
+				if _, ok := entity.Capacities[ctype]; !ok {
+					entity.SetCapacity(ctype, v)
+				}
+			}
+
+			// the keyed metrics of a key are taken from the getter with higher priority as a whole
+			for key, metrics := range e.KeyedMetrics {
+				if _, ok := entity.KeyedMetrics[key]; ok {
+					continue
+				}
+				for ctype, v := range metrics {
+					entity.SetKeyedMetric(key, ctype, v)
+				}
+			}
+
+			for ctype, v := range e.Metrics {
+				sources[e.UID][ctype] = append(sources[e.UID][ctype], &metricSource{getter: r.name, value: v})
+			}
+		}
+	}
+
+	conflicts := []*inter.MetricConflict{}
+	for _, entity := range entities {
+		for ctype, srcs := range sources[entity.UID] {
+			value := p.resolve(srcs)
+			entity.SetMetric(ctype, value)
+
+			if c := newConflict(entity.UID, ctype, srcs, value); c != nil {
+				glog.Warningf("Conflicting %v of entity %v from getters %v: %v, resolved as %v by %v",
+					ctype, entity.UID, c.Getters, c.Values, value, p.Method)
+				conflicts = append(conflicts, c)
+			}
+		}
+	}
+
+	return entities, conflicts
+}
+
+// MergeEdges combines the edges of the getters; an edge reported by several getters
+// is taken from the getter with the highest priority.
+func (p *MergePolicy) MergeEdges(results []*getterResult) []*inter.Edge {
+	p.sortResults(results)
+
+	edges := []*inter.Edge{}
+	seen := make(map[string]bool)
+	for _, r := range results {
+		for _, e := range r.edges {
+			key := e.Key()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			edges = append(edges, e)
+		}
+	}
+
+	return edges
+}
+
+func (p *MergePolicy) resolve(srcs []*metricSource) float64 {
+	result := srcs[0].value
+	for _, s := range srcs[1:] {
+		switch p.Method {
+		case MergeMax:
+			if s.value > result {
+				result = s.value
+			}
+		case MergeSum:
+			result += s.value
+		}
+	}
+	return result
+}
+
+// newConflict returns nil if all the getters report the same value, which is kept as resolved;
+// the same values summed up by the sum policy are reported, as they may be the same metric counted twice
+func newConflict(uid string, ctype proto.CommodityDTO_CommodityType, srcs []*metricSource, resolved float64) *inter.MetricConflict {
+	if len(srcs) < 2 {
+		return nil
+	}
+
+	same := true
+	for _, s := range srcs[1:] {
+		if s.value != srcs[0].value {
+			same = false
+			break
+		}
+	}
+	if same && resolved == srcs[0].value {
+		return nil
+	}
+
+	c := &inter.MetricConflict{
+		UID:       uid,
+		Commodity: ctype,
+		Resolved:  resolved,
+	}
+	for _, s := range srcs {
+		c.Getters = append(c.Getters, s.getter)
+		c.Values = append(c.Values, s.value)
+	}
+	return c
+}
@@ -0,0 +1,113 @@
+package alligator
+
+import (
+	"testing"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+)
+
+func newEntity(uid, category string, tps, latency float64) *inter.EntityMetric {
+	e := inter.NewEntityMetric(uid, inter.AppEntity)
+	e.SetLabel(inter.IP, uid)
+	e.SetLabel(inter.Category, category)
+	e.SetMetric(inter.TpsType, tps)
+	e.SetMetric(inter.LatencyType, latency)
+	return e
+}
+
+func newResults() []*getterResult {
+	return []*getterResult{
+		{
+			name: "redis",
+			metrics: []*inter.EntityMetric{
+				newEntity("10.0.2.3", "Redis", 10, 0),
+				newEntity("10.0.2.4", "Redis", 5, 0),
+			},
+		},
+		{
+			name: "istio",
+			metrics: []*inter.EntityMetric{
+				newEntity("10.0.2.3", "Istio", 3, 0),
+				newEntity("10.0.3.2", "Istio", 1, 20),
+			},
+		},
+	}
+}
+
+func TestMergePolicy_Merge(t *testing.T) {
+	tests := []struct {
+		policy    *MergePolicy
+		tps       float64
+		category  string
+		conflicts int
+	}{
+		{DefaultMergePolicy(), 3, "Istio", 1},
+		{&MergePolicy{Method: MergePrefer, Preferred: []string{"redis"}}, 10, "Redis", 1},
+		{&MergePolicy{Method: MergeMax}, 10, "Istio", 1},
+		{&MergePolicy{Method: MergeSum, Preferred: []string{"redis"}}, 13, "Redis", 1},
+	}
+
+	for _, tt := range tests {
+		entities, conflicts := tt.policy.Merge(newResults())
+		if len(entities) != 3 {
+			t.Errorf("%v: wrong number of entities: %d Vs. 3", tt.policy.Method, len(entities))
+			continue
+		}
+
+		for _, e := range entities {
+			if e.UID != "10.0.2.3" {
+				continue
+			}
+
+			if e.Metrics[inter.TpsType] != tt.tps {
+				t.Errorf("%+v: wrong TPS: %v Vs. %v", tt.policy, e.Metrics[inter.TpsType], tt.tps)
+			}
+			if e.Labels[inter.Category] != tt.category {
+				t.Errorf("%+v: wrong category: %v Vs. %v", tt.policy, e.Labels[inter.Category], tt.category)
+			}
+		}
+
+		// the latency of 10.0.2.3 is the same from both getters
+		if len(conflicts) != tt.conflicts {
+			t.Errorf("%+v: wrong number of conflicts: %d Vs. %d", tt.policy, len(conflicts), tt.conflicts)
+			continue
+		}
+
+		c := conflicts[0]
+		if c.UID != "10.0.2.3" || c.Commodity != inter.TpsType || len(c.Getters) != 2 || c.Resolved != tt.tps {
+			t.Errorf("%+v: wrong conflict: %+v", tt.policy, c)
+		}
+	}
+}
+
+// the same values summed up may be the same metric reported by two getters, so they are reported as conflicts
+func TestMergePolicy_Merge_SumOfSameValues(t *testing.T) {
+	results := []*getterResult{
+		{name: "redis", metrics: []*inter.EntityMetric{newEntity("10.0.2.3", "Redis", 5, 20)}},
+		{name: "istio", metrics: []*inter.EntityMetric{newEntity("10.0.2.3", "Istio", 5, 20)}},
+	}
+
+	entities, conflicts := (&MergePolicy{Method: MergeSum}).Merge(results)
+	if len(entities) != 1 || entities[0].Metrics[inter.TpsType] != 10 || entities[0].Metrics[inter.LatencyType] != 40 {
+		t.Errorf("Wrong merged entities: %+v", entities)
+	}
+	if len(conflicts) != 2 {
+		t.Errorf("Wrong number of conflicts: %d Vs. 2", len(conflicts))
+	}
+	for _, c := range conflicts {
+		if len(c.Getters) != 2 || c.Values[0] != c.Values[1] || c.Resolved != 2*c.Values[0] {
+			t.Errorf("Wrong conflict: %+v", c)
+		}
+	}
+
+	// the same values kept as is by other policies are not conflicts
+	if _, conflicts := DefaultMergePolicy().Merge(results); len(conflicts) != 0 {
+		t.Errorf("Wrong conflicts of prefer policy: %+v", conflicts)
+	}
+}
+
+func TestNewMergePolicy(t *testing.T) {
+	if _, err := NewMergePolicy("min", nil); err == nil {
+		t.Errorf("NewMergePolicy should have failed with unknown method")
+	}
+}
@@ -0,0 +1,140 @@
+package alligator
+
+import (
+	"github.com/golang/glog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+const (
+	// the snapshot is stale if it is older than staleFactor scrape intervals
+	staleFactor = 2
+)
+
+// snapshot is the result of one scrape of all the getters
+type snapshot struct {
+	resp      *inter.MetricResponse
+	timestamp time.Time
+}
+
+// scrapeCall is a scrape in flight, whose result is shared by the callers waiting for it
+type scrapeCall struct {
+	done chan struct{}
+	snap *snapshot
+	err  error
+}
+
+// Scraper refreshes the metrics of the Alligator in background,
+// and keeps the latest result as a snapshot to serve the requests.
+type Scraper struct {
+	alligator *Alligator
+	interval  time.Duration
+
+	// the latest *snapshot
+	latest atomic.Value
+
+	// only one scrape at a time: the concurrent callers wait for the scrape in flight
+	lock     sync.Mutex
+	inflight *scrapeCall
+}
+
+// NewScraper creates a scraper refreshing the metrics every interval;
+// if interval is 0, the metrics are scraped on every request.
+func NewScraper(alligator *Alligator, interval time.Duration) *Scraper {
+	return &Scraper{
+		alligator: alligator,
+		interval:  interval,
+	}
+}
+
+// Health returns the health of the prometheus servers queried by the getters
+func (s *Scraper) Health() []*prometheus.EndpointHealth {
+	return s.alligator.Health()
+}
+
+// Run refreshes the metrics every interval until the stop channel is closed
+func (s *Scraper) Run(stop <-chan struct{}) {
+	if s.interval <= 0 {
+		glog.V(2).Infof("Background scrape is disabled.")
+		return
+	}
+
+	glog.V(2).Infof("Scrape metrics every %v", s.interval)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.scrape()
+	for {
+		select {
+		case <-ticker.C:
+			s.scrape()
+		case <-stop:
+			glog.V(2).Infof("Background scrape is stopped.")
+			return
+		}
+	}
+}
+
+// GetEntityMetrics returns the latest snapshot with its staleness;
+// the metrics are scraped if refresh is true, the background scrape is disabled, or there is no snapshot yet.
+func (s *Scraper) GetEntityMetrics(refresh bool) (*inter.MetricResponse, error) {
+	snap, ok := s.latest.Load().(*snapshot)
+	if refresh || s.interval <= 0 || !ok {
+		var err error
+		if snap, err = s.scrape(); err != nil {
+			return nil, err
+		}
+	}
+
+	// copy the response, as the snapshot is shared by the requests
+	resp := *snap.resp
+	age := time.Since(snap.timestamp)
+	resp.Timestamp = snap.timestamp.Unix()
+	resp.AgeSeconds = age.Seconds()
+	resp.Stale = s.interval > 0 && age > staleFactor*s.interval
+	return &resp, nil
+}
+
+// scrape scrapes the metrics, or waits for the scrape in flight and returns its result,
+// so that the concurrent requests don't send duplicate queries to prometheus
+func (s *Scraper) scrape() (*snapshot, error) {
+	s.lock.Lock()
+	if call := s.inflight; call != nil {
+		s.lock.Unlock()
+		<-call.done
+		return call.snap, call.err
+	}
+	call := &scrapeCall{done: make(chan struct{})}
+	s.inflight = call
+	s.lock.Unlock()
+
+	call.snap, call.err = s.doScrape()
+
+	s.lock.Lock()
+	s.inflight = nil
+	s.lock.Unlock()
+	close(call.done)
+
+	return call.snap, call.err
+}
+
+func (s *Scraper) doScrape() (*snapshot, error) {
+	start := time.Now()
+	resp, err := s.alligator.GetEntityMetrics()
+	if err != nil {
+		glog.Errorf("Failed to scrape entity metrics: %v", err)
+		return nil, err
+	}
+	glog.V(3).Infof("Scraped %d entities in %v", len(resp.Data), time.Since(start))
+
+	snap := &snapshot{
+		resp:      resp,
+		timestamp: start,
+	}
+	s.latest.Store(snap)
+	return snap, nil
+}
@@ -0,0 +1,106 @@
+package alligator
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
+)
+
+type countingGetter struct {
+	mockGetter
+	count int32
+}
+
+func (g *countingGetter) GetEntityMetric(client prometheus.MetricClient) ([]*inter.EntityMetric, error) {
+	atomic.AddInt32(&g.count, 1)
+	return g.mockGetter.GetEntityMetric(client)
+}
+
+func TestScraper_GetEntityMetrics_OnDemand(t *testing.T) {
+	g := &countingGetter{mockGetter: mockGetter{name: "a"}}
+	s := NewScraper(newTestAlligator(t, g), 0)
+
+	for i := 0; i < 3; i++ {
+		resp, err := s.GetEntityMetrics(false)
+		if err != nil || len(resp.Data) != 1 || resp.Stale {
+			t.Errorf("Wrong response: %+v, %v", resp, err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&g.count); n != 3 {
+		t.Errorf("Metrics should be scraped on every request: %d Vs. 3", n)
+	}
+}
+
+func TestScraper_GetEntityMetrics_Snapshot(t *testing.T) {
+	g := &countingGetter{mockGetter: mockGetter{name: "a"}}
+	s := NewScraper(newTestAlligator(t, g), time.Hour)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.Run(stop)
+
+	// wait for the first scrape
+	for i := 0; i < 100 && atomic.LoadInt32(&g.count) < 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for i := 0; i < 3; i++ {
+		resp, err := s.GetEntityMetrics(false)
+		if err != nil || len(resp.Data) != 1 || resp.Stale || resp.Timestamp == 0 {
+			t.Errorf("Wrong response: %+v, %v", resp, err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&g.count); n != 1 {
+		t.Errorf("The snapshot should be served: %d Vs. 1", n)
+	}
+
+	if _, err := s.GetEntityMetrics(true); err != nil {
+		t.Errorf("Failed to refresh metrics: %v", err)
+	}
+
+	if n := atomic.LoadInt32(&g.count); n != 2 {
+		t.Errorf("Metrics should be scraped on refresh: %d Vs. 2", n)
+	}
+}
+
+func TestScraper_GetEntityMetrics_Concurrent(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		refresh  bool
+	}{
+		// scraped on every request
+		{0, false},
+		// refreshed on request
+		{time.Hour, true},
+		// no snapshot yet
+		{time.Hour, false},
+	}
+
+	for _, tt := range tests {
+		g := &countingGetter{mockGetter: mockGetter{name: "a", delay: 200 * time.Millisecond}}
+		s := NewScraper(newTestAlligator(t, g), tt.interval)
+
+		// the concurrent requests wait for the scrape in flight
+		var wg sync.WaitGroup
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if resp, err := s.GetEntityMetrics(tt.refresh); err != nil || len(resp.Data) != 1 {
+					t.Errorf("Wrong response: %+v, %v", resp, err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if n := atomic.LoadInt32(&g.count); n != 1 {
+			t.Errorf("%+v: the concurrent requests should share one scrape: %d Vs. 1", tt, n)
+		}
+	}
+}
@@ -10,10 +10,56 @@ const (
 	Port     = "port"
 	Name     = "name"
 	Category = "category"
+	Source   = "source"
 
 	AppEntity  = proto.EntityDTO_APPLICATION
 	VAppEntity = proto.EntityDTO_VIRTUAL_APPLICATION
+	VMEntity   = proto.EntityDTO_VIRTUAL_MACHINE
+
+	BusinessAppEntity = proto.EntityDTO_BUSINESS_APPLICATION
+
+	ContainerEntity = proto.EntityDTO_CONTAINER
+	PodEntity       = proto.EntityDTO_CONTAINER_POD
 
 	LatencyType = proto.CommodityDTO_RESPONSE_TIME
 	TpsType     = proto.CommodityDTO_TRANSACTION
+
+	// the commodities of the databases
+	DBMemType          = proto.CommodityDTO_DB_MEM
+	ConnectionType     = proto.CommodityDTO_CONNECTION
+	DBCacheHitRateType = proto.CommodityDTO_DB_CACHE_HIT_RATE
+
+	// the commodities of the JVMs
+	HeapType           = proto.CommodityDTO_HEAP
+	CollectionTimeType = proto.CommodityDTO_COLLECTION_TIME
+	ThreadsType        = proto.CommodityDTO_THREADS
+
+	// the commodities of the VMs: VCPU in MHz, VMEM in KB, and the throughputs in KB/s;
+	// VCPU of the containers and pods is in millicores
+	VCPUType          = proto.CommodityDTO_VCPU
+	VMemType          = proto.CommodityDTO_VMEM
+	NetThroughputType = proto.CommodityDTO_NET_THROUGHPUT
+	IOThroughputType  = proto.CommodityDTO_IO_THROUGHPUT
+)
+
+//Attributes
+const (
+	// the rate of all the requests, in requests per second
+	RequestRate = "request_rate"
+	// the ratio of the requests with 4xx and 5xx response codes, in [0, 1]
+	ErrorRate4xx = "error_rate_4xx"
+	ErrorRate5xx = "error_rate_5xx"
+
+	// the rate of all the statements executed by a MySQL server, including the ones of the stored programs
+	QueriesRate = "queries_rate"
+
+	// the rates of the bytes received and sent by a Kafka broker, in bytes per second
+	BytesInRate  = "bytes_in_rate"
+	BytesOutRate = "bytes_out_rate"
+	// the lag of a Kafka consumer group, in messages; "consumer_lag/<topic>" is the lag of one topic
+	ConsumerLag = "consumer_lag"
+	// the percentage of the CFS periods throttled of a container or pod
+	CPUThrottling = "cpu_throttling"
+	// the ratio of the successful synthetic probes of an endpoint, in [0, 1]
+	Availability = "availability"
 )
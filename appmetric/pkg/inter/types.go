@@ -1,6 +1,7 @@
 package inter
 
 import (
+	"fmt"
 	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
 )
 
@@ -9,12 +10,73 @@ type EntityMetric struct {
 	Type    proto.EntityDTO_EntityType                   `json:"type,omitempty"`
 	Labels  map[string]string                            `json:"labels,omitempty"`
 	Metrics map[proto.CommodityDTO_CommodityType]float64 `json:"metrics,omitempty"`
+
+	// numeric values which are not commodities, e.g., the error rates
+	Attributes map[string]float64 `json:"attributes,omitempty"`
+
+	// the capacities of the commodities reported with the metrics, e.g., the max memory of a database;
+	// the default capacities are used by the probe if not reported
+	Capacities map[proto.CommodityDTO_CommodityType]float64 `json:"capacities,omitempty"`
+
+	// the metrics broken down by a key, e.g., the TPS of every gRPC method,
+	// reported by the probe as the commodities of the key in addition to the ones of Metrics
+	KeyedMetrics map[string]map[proto.CommodityDTO_CommodityType]float64 `json:"keyedMetrics,omitempty"`
+}
+
+// Edge is a dependency between two entities: the caller consumes the callee,
+// with the metrics (e.g., TPS and latency) of the calls from the caller to the callee.
+type Edge struct {
+	Caller     string                                       `json:"caller"`
+	CallerType proto.EntityDTO_EntityType                   `json:"callerType"`
+	Callee     string                                       `json:"callee"`
+	CalleeType proto.EntityDTO_EntityType                   `json:"calleeType"`
+	Metrics    map[proto.CommodityDTO_CommodityType]float64 `json:"metrics,omitempty"`
+}
+
+// Attachment carries the attributes of the entities reported by other getters, matched by the value of a label:
+// e.g., the lag of a Kafka consumer group is attached to the applications whose "workload" label is the group.
+type Attachment struct {
+	Label      string             `json:"label"`
+	Value      string             `json:"value"`
+	Attributes map[string]float64 `json:"attributes,omitempty"`
+}
+
+// GetterStatus is the result of one entity metric getter
+type GetterStatus struct {
+	Name     string `json:"name"`
+	Category string `json:"category,omitempty"`
+	// label of the prometheus source the getter ran against
+	Source      string `json:"source,omitempty"`
+	Success     bool   `json:"success"`
+	Error       string `json:"error,omitempty"`
+	EntityCount int    `json:"entityCount"`
+	// time used by the getter in milliseconds
+	DurationMs float64 `json:"durationMs"`
+	// warnings returned by the prometheus API, e.g., partial responses
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// MetricConflict is a metric of an entity reported by several getters with different values
+type MetricConflict struct {
+	UID       string                           `json:"uid"`
+	Commodity proto.CommodityDTO_CommodityType `json:"commodity"`
+	Getters   []string                         `json:"getters"`
+	Values    []float64                        `json:"values"`
+	Resolved  float64                          `json:"resolved"`
 }
 
 type MetricResponse struct {
-	Status  int             `json:"status"`
-	Message string          `json:"message:omitemtpy"`
-	Data    []*EntityMetric `json:"data:omitempty"`
+	Status    int               `json:"status"`
+	Message   string            `json:"message:omitemtpy"`
+	Data      []*EntityMetric   `json:"data:omitempty"`
+	Getters   []*GetterStatus   `json:"getters,omitempty"`
+	Conflicts []*MetricConflict `json:"conflicts,omitempty"`
+	Edges     []*Edge           `json:"edges,omitempty"`
+
+	// when the metrics were scraped (unix seconds), and their age when served
+	Timestamp  int64   `json:"timestamp,omitempty"`
+	AgeSeconds float64 `json:"ageSeconds,omitempty"`
+	Stale      bool    `json:"stale,omitempty"`
 }
 
 func NewEntityMetric(id string, t proto.EntityDTO_EntityType) *EntityMetric {
@@ -23,11 +85,41 @@ func NewEntityMetric(id string, t proto.EntityDTO_EntityType) *EntityMetric {
 		Type:    t,
 		Labels:  make(map[string]string),
 		Metrics: make(map[proto.CommodityDTO_CommodityType]float64),
+
+		Attributes: make(map[string]float64),
+		Capacities: make(map[proto.CommodityDTO_CommodityType]float64),
 	}
 
 	return m
 }
 
+func NewEdge(caller string, callerType proto.EntityDTO_EntityType, callee string, calleeType proto.EntityDTO_EntityType) *Edge {
+	return &Edge{
+		Caller:     caller,
+		CallerType: callerType,
+		Callee:     callee,
+		CalleeType: calleeType,
+		Metrics:    make(map[proto.CommodityDTO_CommodityType]float64),
+	}
+}
+
+// Key identifies the edge by its ends
+func (e *Edge) Key() string {
+	return fmt.Sprintf("%d:%s->%d:%s", e.CallerType, e.Caller, e.CalleeType, e.Callee)
+}
+
+func (e *Edge) SetMetric(cname proto.CommodityDTO_CommodityType, value float64) {
+	e.Metrics[cname] = value
+}
+
+func NewAttachment(label, value string) *Attachment {
+	return &Attachment{
+		Label:      label,
+		Value:      value,
+		Attributes: make(map[string]float64),
+	}
+}
+
 func (e *EntityMetric) SetLabel(name, value string) {
 	e.Labels[name] = value
 }
@@ -36,6 +128,30 @@ func (e *EntityMetric) SetMetric(cname proto.CommodityDTO_CommodityType, value f
 	e.Metrics[cname] = value
 }
 
+func (e *EntityMetric) SetCapacity(cname proto.CommodityDTO_CommodityType, value float64) {
+	if e.Capacities == nil {
+		e.Capacities = make(map[proto.CommodityDTO_CommodityType]float64)
+	}
+	e.Capacities[cname] = value
+}
+
+func (e *EntityMetric) SetKeyedMetric(key string, cname proto.CommodityDTO_CommodityType, value float64) {
+	if e.KeyedMetrics == nil {
+		e.KeyedMetrics = make(map[string]map[proto.CommodityDTO_CommodityType]float64)
+	}
+	if _, ok := e.KeyedMetrics[key]; !ok {
+		e.KeyedMetrics[key] = make(map[proto.CommodityDTO_CommodityType]float64)
+	}
+	e.KeyedMetrics[key][cname] = value
+}
+
+func (e *EntityMetric) SetAttribute(name string, value float64) {
+	if e.Attributes == nil {
+		e.Attributes = make(map[string]float64)
+	}
+	e.Attributes[name] = value
+}
+
 func NewMetricResponse() *MetricResponse {
 	return &MetricResponse{
 		Status:  0,
@@ -56,3 +172,18 @@ func (r *MetricResponse) SetMetrics(dat []*EntityMetric) {
 func (r *MetricResponse) AddMetric(m *EntityMetric) {
 	r.Data = append(r.Data, m)
 }
+
+func (r *MetricResponse) AddGetterStatus(s *GetterStatus) {
+	r.Getters = append(r.Getters, s)
+}
+
+// FailedGetters returns the status of the failed getters
+func (r *MetricResponse) FailedGetters() []*GetterStatus {
+	result := []*GetterStatus{}
+	for _, s := range r.Getters {
+		if !s.Success {
+			result = append(result, s)
+		}
+	}
+	return result
+}
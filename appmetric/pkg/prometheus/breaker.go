@@ -0,0 +1,138 @@
+package prometheus
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"sync"
+	"time"
+)
+
+// The states of the circuit breaker
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// EndpointHealth is the health of a prometheus server, reported by its circuit breaker
+type EndpointHealth struct {
+	Address string `json:"address"`
+	Source  string `json:"source,omitempty"`
+	State   string `json:"state"`
+
+	ConsecutiveFailures int    `json:"consecutiveFailures"`
+	LastError           string `json:"lastError,omitempty"`
+
+	// when the open breaker will send a trial query (unix seconds)
+	RetryAt int64 `json:"retryAt,omitempty"`
+}
+
+// circuitBreaker fails the queries to a prometheus server fast after repeated failures:
+// it opens after threshold consecutive failed queries; after the cooldown, it is half-open,
+// and lets one trial query pass: the breaker is closed if the trial succeeds, otherwise it opens again.
+type circuitBreaker struct {
+	host      string
+	threshold int
+	cooldown  time.Duration
+
+	state     string
+	failures  int
+	lastError string
+	openedAt  time.Time
+
+	lock sync.Mutex
+}
+
+func newCircuitBreaker(host string, threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		host:      host,
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// allow returns an error if the query should fail fast
+func (b *circuitBreaker) allow() error {
+	if b.threshold < 1 {
+		return nil
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return fmt.Errorf("circuit breaker of %v is open after %d failures: %v", b.host, b.failures, b.lastError)
+		}
+		glog.V(2).Infof("Circuit breaker of %v is half-open, sending a trial query", b.host)
+		b.state = BreakerHalfOpen
+		return nil
+	case BreakerHalfOpen:
+		return fmt.Errorf("circuit breaker of %v is half-open, waiting for the trial query", b.host)
+	}
+
+	return nil
+}
+
+// succeed records a query answered by the server
+func (b *circuitBreaker) succeed() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state != BreakerClosed {
+		glog.V(1).Infof("Circuit breaker of %v is closed", b.host)
+	}
+	b.state = BreakerClosed
+	b.failures = 0
+	b.lastError = ""
+}
+
+// fail records a query failed by a transient error
+func (b *circuitBreaker) fail(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures++
+	b.lastError = err.Error()
+	if b.threshold < 1 {
+		return
+	}
+
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		if b.state != BreakerOpen {
+			glog.Warningf("Circuit breaker of %v is open after %d failures: %v", b.host, b.failures, err)
+		}
+		b.state = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// abort records a query aborted by the client, e.g., its deadline is exceeded:
+// the trial query of a half-open breaker will be sent again.
+func (b *circuitBreaker) abort() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+	}
+}
+
+func (b *circuitBreaker) health() *EndpointHealth {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	h := &EndpointHealth{
+		Address:             b.host,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+
+	if b.state == BreakerOpen {
+		h.RetryAt = b.openedAt.Add(b.cooldown).Unix()
+	}
+	return h
+}
@@ -1,39 +1,71 @@
 package prometheus
 
 import (
-	"crypto/tls"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/golang/glog"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	apiPath      = "/api/v1/"
-	apiQueryPath = "/api/v1/query"
+	apiPath           = "/api/v1/"
+	apiQueryPath      = "/api/v1/query"
+	apiQueryRangePath = "/api/v1/query_range"
 
 	defaultTimeOut = time.Duration(60 * time.Second)
 )
 
+// MetricClient gets the metrics from a prometheus server, or a group of prometheus servers
+type MetricClient interface {
+	GetMetrics(input RequestInput) ([]MetricData, error)
+	GetRangeMetrics(input RangeRequestInput) ([]MetricData, error)
+	WithContext(ctx context.Context) MetricClient
+	// the health of the prometheus servers
+	Health() []*EndpointHealth
+}
+
+// ensure RestClient implement the requisite interfaces
+var _ MetricClient = &RestClient{}
+
 type RestClient struct {
 	client   *http.Client
 	host     string
 	username string
 	password string
+
+	bearerTokenFile string
+
+	// static headers, including the tenant header, and extra query parameters
+	headers map[string]string
+	params  map[string]string
+
+	retry   *retryPolicy
+	breaker *circuitBreaker
+
+	// the context of the requests, can be set by WithContext()
+	ctx context.Context
 }
 
 // NewRestClient create a new prometheus HTTP API client
 func NewRestClient(host string) (*RestClient, error) {
+	return NewRestClientWithConfig(NewClientConfig(host))
+}
+
+// NewRestClientWithConfig create a new prometheus HTTP API client with the auth and TLS settings
+func NewRestClientWithConfig(conf *ClientConfig) (*RestClient, error) {
 	//1. get http client
 	client := &http.Client{
 		Timeout: defaultTimeOut,
 	}
 
 	//2. check whether it is using ssl
+	host := conf.Address
 	if !strings.HasPrefix(host, "http") {
 		host = "http://" + host
 	}
@@ -44,17 +76,54 @@ func NewRestClient(host string) (*RestClient, error) {
 		return nil, err
 	}
 	if addr.Scheme == "https" {
+		tlsConfig, err := conf.TLS.newTLSConfig()
+		if err != nil {
+			glog.Errorf("Invalid TLS config: %v", err)
+			return nil, err
+		}
+
 		tr := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			TLSClientConfig: tlsConfig,
 		}
 		client.Transport = tr
 	}
 
 	glog.V(2).Infof("Prometheus server address is: %v", host)
 
+	headers := make(map[string]string)
+	for k, v := range conf.Headers {
+		headers[k] = v
+	}
+	if len(conf.TenantID) > 0 {
+		tenantHeader := conf.TenantHeader
+		if len(tenantHeader) < 1 {
+			tenantHeader = DefaultTenantHeader
+		}
+		headers[tenantHeader] = conf.TenantID
+	}
+
+	retry, err := conf.Retry.newRetryPolicy()
+	if err != nil {
+		glog.Errorf("Invalid retry config: %v", err)
+		return nil, err
+	}
+
+	breaker, err := conf.Breaker.newCircuitBreaker(host)
+	if err != nil {
+		glog.Errorf("Invalid circuit breaker config: %v", err)
+		return nil, err
+	}
+
 	return &RestClient{
-		client: client,
-		host:   host,
+		client:          client,
+		host:            host,
+		username:        conf.Username,
+		password:        conf.Password,
+		bearerTokenFile: conf.BearerTokenFile,
+		headers:         headers,
+		params:          conf.Params,
+		retry:           retry,
+		breaker:         breaker,
 	}, nil
 }
 
@@ -64,6 +133,14 @@ func (c *RestClient) SetUser(username, password string) {
 	c.password = password
 }
 
+// WithContext returns a shallow copy of the client, whose requests are bound to the context:
+// the queries will be aborted when the context is cancelled or its deadline is exceeded.
+func (c *RestClient) WithContext(ctx context.Context) MetricClient {
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
 // Query query the prometheus server, and return the rawData
 func (c *RestClient) Query(query string) (*RawData, error) {
 	query = strings.TrimSpace(query)
@@ -73,8 +150,87 @@ func (c *RestClient) Query(query string) (*RawData, error) {
 		return nil, err
 	}
 
-	p := fmt.Sprintf("%v%v", c.host, apiQueryPath)
-	glog.V(4).Infof("path=%v, query=%v", p, query)
+	params := url.Values{}
+	params.Set("query", query)
+	return c.send(apiQueryPath, params)
+}
+
+// QueryRange send a range query to the prometheus server, and return the rawData;
+// the result of a range query is a 'matrix': a list of series sampled at every step in [start, end]
+func (c *RestClient) QueryRange(query string, start, end time.Time, step time.Duration) (*RawData, error) {
+	query = strings.TrimSpace(query)
+	if len(query) < 1 {
+		err := fmt.Errorf("Prometheus query is empty")
+		glog.Errorf("%v", err)
+		return nil, err
+	}
+
+	if !end.After(start) {
+		err := fmt.Errorf("Invalid query range: start(%v) is not before end(%v)", start, end)
+		glog.Errorf("%v", err)
+		return nil, err
+	}
+
+	if step <= 0 {
+		err := fmt.Errorf("Invalid query step: %v", step)
+		glog.Errorf("%v", err)
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", formatTime(start))
+	params.Set("end", formatTime(end))
+	params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+	return c.send(apiQueryRangePath, params)
+}
+
+// send a GET request with the query parameters to the api path, and decode the response;
+// the transient errors are retried, and the query fails fast if the circuit breaker is open.
+func (c *RestClient) send(path string, params url.Values) (*RawData, error) {
+	//1. fail fast if the server is unhealthy
+	if err := c.breaker.allow(); err != nil {
+		glog.Errorf("%v", err)
+		return nil, err
+	}
+
+	//2. send with retries
+	for i := 0; ; i++ {
+		data, err := c.sendOnce(path, params)
+		if err == nil {
+			c.breaker.succeed()
+			return data, nil
+		}
+
+		if !isTransient(err) {
+			if c.ctx != nil && c.ctx.Err() != nil {
+				c.breaker.abort()
+			} else {
+				// the server is alive, e.g., a bad query
+				c.breaker.succeed()
+			}
+			return nil, err
+		}
+
+		if i >= c.retry.maxRetries {
+			c.breaker.fail(err)
+			return nil, err
+		}
+
+		backoff := c.retry.getBackoff(i, getRetryAfter(err))
+		glog.Warningf("Query to %v failed (attempt %d of %d), retry in %v: %v",
+			c.host, i+1, c.retry.maxRetries+1, backoff, err)
+		if err := c.sleep(backoff); err != nil {
+			c.breaker.abort()
+			return nil, err
+		}
+	}
+}
+
+// sendOnce sends the request once; the errors worth a retry are marked as transient
+func (c *RestClient) sendOnce(path string, params url.Values) (*RawData, error) {
+	p := fmt.Sprintf("%v%v", c.host, path)
+	glog.V(4).Infof("path=%v, params=%v", p, params)
 
 	req, err := http.NewRequest("GET", p, nil)
 	if err != nil {
@@ -82,38 +238,68 @@ func (c *RestClient) Query(query string) (*RawData, error) {
 		return nil, err
 	}
 
-	//1. set query
-	q := req.URL.Query()
-	q.Set("query", query)
-	req.URL.RawQuery = q.Encode()
+	if c.ctx != nil {
+		req = req.WithContext(c.ctx)
+	}
+
+	//1. set query, with the extra parameters
+	for k, v := range c.params {
+		if _, exist := params[k]; !exist {
+			params.Set(k, v)
+		}
+	}
+	req.URL.RawQuery = params.Encode()
 
 	//2. set headers
-	req.Header.Set("Accept", "application/json")
-	if len(c.username) > 0 {
-		req.SetBasicAuth(c.username, c.password)
+	if err := c.setHeaders(req); err != nil {
+		glog.Errorf("Failed to set headers: %v", err)
+		return nil, err
 	}
 
+	//3. send the request
 	resp, err := c.client.Do(req)
 	if err != nil {
 		glog.Errorf("Failed to send http request: %v", err)
-		return nil, err
+		if c.ctx != nil && c.ctx.Err() != nil {
+			return nil, err
+		}
+		return nil, &queryError{msg: err.Error(), transient: true}
 	}
 	defer resp.Body.Close()
 
 	result, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		glog.Errorf("Failed to read response: %v", err)
-		return nil, err
+		return nil, &queryError{msg: err.Error(), transient: true}
 	}
 
+	//4. decode the response
 	var ss promeResponse
-	if err := json.Unmarshal(result, &ss); err != nil {
-		glog.Errorf("Failed to unmarshall respone: %v", err)
+	if err := json.Unmarshal(result, &ss); err != nil || len(ss.Status) < 1 {
+		// e.g., the html page of a proxy
+		err := &queryError{
+			msg: fmt.Sprintf("Invalid response from %v, not a JSON of prometheus API: %v (Content-Type: %v): %v",
+				p, resp.Status, resp.Header.Get("Content-Type"), bodySnippet(result)),
+			transient:  isTransientStatus(resp.StatusCode),
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+		glog.Errorf("%v", err)
 		return nil, err
 	}
 
 	if ss.Status == "error" {
-		return nil, fmt.Errorf(ss.Error)
+		return nil, &queryError{
+			msg:        ss.Error,
+			transient:  isTransientStatus(resp.StatusCode),
+			retryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	if len(ss.Warnings) > 0 {
+		glog.Warningf("Query of %v returned warnings: %v", c.host, ss.Warnings)
+		if collector := warningCollectorFrom(c.ctx); collector != nil {
+			collector.Add(c.host, ss.Warnings)
+		}
 	}
 
 	glog.V(4).Infof("resp: %++v", string(result))
@@ -121,19 +307,74 @@ func (c *RestClient) Query(query string) (*RawData, error) {
 	return ss.Data, nil
 }
 
+// sleep waits for the duration, or until the context is done
+func (c *RestClient) sleep(d time.Duration) error {
+	if c.ctx == nil {
+		time.Sleep(d)
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
+// Health returns the health of the server, reported by its circuit breaker
+func (c *RestClient) Health() []*EndpointHealth {
+	return []*EndpointHealth{c.breaker.health()}
+}
+
+// bodySnippet returns the beginning of the response body for the error message
+func bodySnippet(body []byte) string {
+	const maxLen = 128
+	s := strings.TrimSpace(string(body))
+	if len(s) > maxLen {
+		s = s[:maxLen] + "..."
+	}
+	return s
+}
+
 // GetMetrics send a query to prometheus server, and return a list of MetricData
 //   Note: it only support 'vector query: the data in the response is a 'vector'
 //          not a 'matrix' (range query), 'string', or 'scalar'
 //   (1) the RequestInput will generate a query;
 //   (2) the RequestInput will parse the response into a list of MetricData
 func (c *RestClient) GetMetrics(input RequestInput) ([]MetricData, error) {
-	result := []MetricData{}
+	resp, err := c.queryVector(input.GetQuery())
+	if err != nil {
+		glog.Errorf("Failed to get metrics from prometheus: %v", err)
+		return []MetricData{}, err
+	}
+
+	return parseVector(input, resp), nil
+}
+
+// GetRangeMetrics send a range query to prometheus server, and return a list of MetricData.
+// Note: the data in the response should be a 'matrix':
+// (1) the RangeRequestInput will generate a query and its time range;
+// (2) the RangeRequestInput will reduce each series into a MetricData
+func (c *RestClient) GetRangeMetrics(input RangeRequestInput) ([]MetricData, error) {
+	start, end, step := input.GetRange()
+	resp, err := c.queryMatrix(input.GetQuery(), start, end, step)
+	if err != nil {
+		glog.Errorf("Failed to get range metrics from prometheus: %v", err)
+		return []MetricData{}, err
+	}
+
+	return parseMatrix(input, resp), nil
+}
 
+// queryVector send a query, and decode the 'vector' result
+func (c *RestClient) queryVector(query string) ([]RawMetric, error) {
 	//1. query
-	qresult, err := c.Query(input.GetQuery())
+	qresult, err := c.Query(query)
 	if err != nil {
-		glog.Errorf("Failed to get metrics from prometheus: %v", err)
-		return result, err
+		return nil, err
 	}
 
 	glog.V(4).Infof("result.type=%v, \n result: %+v",
@@ -142,17 +383,49 @@ func (c *RestClient) GetMetrics(input RequestInput) ([]MetricData, error) {
 	if qresult.ResultType != "vector" {
 		err := fmt.Errorf("Unsupported result type: %v", qresult.ResultType)
 		glog.Errorf(err.Error())
-		return result, err
+		return nil, err
 	}
 
 	//2. parse/decode the value
 	var resp []RawMetric
 	if err := json.Unmarshal(qresult.Result, &resp); err != nil {
 		glog.Errorf("Failed to unmarshal: %v", err)
-		return result, err
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// queryMatrix send a range query, and decode the 'matrix' result
+func (c *RestClient) queryMatrix(query string, start, end time.Time, step time.Duration) ([]RawSeries, error) {
+	//1. query
+	qresult, err := c.QueryRange(query, start, end, step)
+	if err != nil {
+		return nil, err
 	}
 
-	//3. assign the values
+	glog.V(4).Infof("result.type=%v, \n result: %+v",
+		qresult.ResultType, string(qresult.Result))
+
+	if qresult.ResultType != "matrix" {
+		err := fmt.Errorf("Unsupported result type: %v", qresult.ResultType)
+		glog.Errorf("%v", err)
+		return nil, err
+	}
+
+	//2. parse/decode the series
+	var resp []RawSeries
+	if err := json.Unmarshal(qresult.Result, &resp); err != nil {
+		glog.Errorf("Failed to unmarshal: %v", err)
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// parseVector parses the raw metrics by the RequestInput
+func parseVector(input RequestInput, resp []RawMetric) []MetricData {
+	result := []MetricData{}
 	for i := range resp {
 		d, err := input.Parse(&(resp[i]))
 		if err != nil {
@@ -163,7 +436,23 @@ func (c *RestClient) GetMetrics(input RequestInput) ([]MetricData, error) {
 		result = append(result, d)
 	}
 
-	return result, nil
+	return result
+}
+
+// parseMatrix reduces each series to a value by the RangeRequestInput
+func parseMatrix(input RangeRequestInput, resp []RawSeries) []MetricData {
+	result := []MetricData{}
+	for i := range resp {
+		d, err := input.ParseSeries(&(resp[i]))
+		if err != nil {
+			glog.Errorf("Parse series failed: %v", err)
+			continue
+		}
+
+		result = append(result, d)
+	}
+
+	return result
 }
 
 // GetJobs  get the all the jobs in the current prometheus server
@@ -178,7 +467,10 @@ func (c *RestClient) GetJobs() (string, error) {
 		glog.Errorf("Failed to generate a http.request: %v", err)
 		return "", err
 	}
-	req.Header.Set("Accept", "application/json")
+	if err := c.setHeaders(req); err != nil {
+		glog.Errorf("Failed to set headers: %v", err)
+		return "", err
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -199,3 +491,28 @@ func (c *RestClient) GetJobs() (string, error) {
 
 	return string(result), nil
 }
+
+// setHeaders sets the static, accept and authorization headers
+func (c *RestClient) setHeaders(req *http.Request) error {
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	if len(c.bearerTokenFile) > 0 {
+		token, err := readBearerToken(c.bearerTokenFile)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if len(c.username) > 0 {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	return nil
+}
+
+// format the time as the unix timestamp (in seconds) accepted by the prometheus API
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', -1, 64)
+}
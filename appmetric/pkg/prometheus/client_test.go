@@ -1,7 +1,15 @@
 package prometheus
 
 import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestNewClient(t *testing.T) {
@@ -20,3 +28,205 @@ func TestNewClient(t *testing.T) {
 		}
 	}
 }
+
+func TestRestClient_GetRangeMetrics(t *testing.T) {
+	body := `{"status":"success","data":{"resultType":"matrix","result":[
+		{"metric":{"instance":"10.0.2.3:9121"},"values":[[1530000000,"1"],[1530000015,"3"],[1530000030,"2"]]},
+		{"metric":{"instance":"10.0.3.2:9121"},"values":[[1530000000,"5"],[1530000015,"NaN"]]}]}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != apiQueryRangePath {
+			t.Errorf("Wrong path: %v", r.URL.Path)
+		}
+		for _, p := range []string{"query", "start", "end", "step"} {
+			if len(r.URL.Query().Get(p)) < 1 {
+				t.Errorf("Parameter %v is not set: %v", p, r.URL.RawQuery)
+			}
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client, err := NewRestClient(server.URL)
+	if err != nil {
+		t.Errorf("Failed to create rest client: %v", err)
+		return
+	}
+
+	input := NewBasicRangeInput(3*time.Minute, 15*time.Second, NewMaxReducer())
+	input.SetQuery("rate(redis_commands_processed_total[1m])")
+	result, err := client.GetRangeMetrics(input)
+	if err != nil {
+		t.Errorf("Failed to get range metrics: %v", err)
+		return
+	}
+
+	expected := map[string]float64{
+		"10.0.2.3:9121": 3,
+		"10.0.3.2:9121": 5,
+	}
+	if len(result) != len(expected) {
+		t.Errorf("Wrong number of series: %d Vs. %d", len(result), len(expected))
+	}
+
+	for _, d := range result {
+		m := d.(*BasicMetricData)
+		if v := expected[m.Labels["instance"]]; v != m.GetValue() {
+			t.Errorf("Wrong value for %v: %v Vs. %v", m.Labels["instance"], m.GetValue(), v)
+		}
+	}
+}
+
+func TestRestClient_GetRangeMetrics_Vector(t *testing.T) {
+	body := `{"status":"success","data":{"resultType":"vector","result":[]}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client, _ := NewRestClient(server.URL)
+	input := NewBasicRangeInput(3*time.Minute, 15*time.Second, NewAvgReducer())
+	input.SetQuery("up")
+	if _, err := client.GetRangeMetrics(input); err == nil {
+		t.Errorf("GetRangeMetrics should have failed with a vector result")
+	}
+}
+
+func TestRestClient_BearerTokenFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prometheus")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	expected := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "Bearer "+expected {
+			t.Errorf("Wrong Authorization header: %v Vs. Bearer %v", auth, expected)
+		}
+		w.Write([]byte(`{"status":"success","data":["prometheus"]}`))
+	}))
+	defer server.Close()
+
+	conf := NewClientConfig(server.URL)
+	conf.BearerTokenFile = tokenFile
+	client, err := NewRestClientWithConfig(conf)
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+
+	// the token is rotated
+	for _, token := range []string{"token1", "token2"} {
+		expected = token
+		if err := ioutil.WriteFile(tokenFile, []byte(token+"\n"), 0600); err != nil {
+			t.Fatalf("Failed to write token file: %v", err)
+		}
+
+		if _, err := client.GetJobs(); err != nil {
+			t.Errorf("Failed to get jobs: %v", err)
+		}
+	}
+}
+
+func TestRestClient_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":["prometheus"]}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "prometheus")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	caFile := filepath.Join(dir, "ca.crt")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+
+	//1. the server certificate is verified by the CA bundle
+	conf := NewClientConfig(server.URL)
+	conf.TLS = TLSConfig{CAFile: caFile, ServerName: "example.com"}
+	client, err := NewRestClientWithConfig(conf)
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+	if _, err := client.GetJobs(); err != nil {
+		t.Errorf("Failed to get jobs with CA file: %v", err)
+	}
+
+	//2. the server certificate cannot be verified without the CA bundle
+	conf.TLS = TLSConfig{}
+	client, err = NewRestClientWithConfig(conf)
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+	if _, err := client.GetJobs(); err == nil {
+		t.Errorf("Verifying server certificate should have failed without CA file")
+	}
+
+	//3. the server certificate is verified by default
+	client, err = NewRestClientWithConfig(NewClientConfig(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+	if _, err := client.GetJobs(); err == nil {
+		t.Errorf("Verifying server certificate should have failed by default")
+	}
+
+	//4. invalid CA file
+	conf.TLS = TLSConfig{CAFile: filepath.Join(dir, "none.crt")}
+	if _, err := NewRestClientWithConfig(conf); err == nil {
+		t.Errorf("Creating rest client should have failed with missing CA file")
+	}
+}
+
+func TestRestClient_Tenant(t *testing.T) {
+	body := `{"status":"success","warnings":["no StoreAPIs matched"],"data":{"resultType":"vector","result":[]}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if v := r.Header.Get(DefaultTenantHeader); v != "team-a" {
+			t.Errorf("Wrong tenant header: %v", v)
+		}
+		if v := r.Header.Get("X-Custom"); v != "foo" {
+			t.Errorf("Wrong static header: %v", v)
+		}
+		if v := r.URL.Query().Get("partial_response"); v != "true" {
+			t.Errorf("Wrong extra parameter: %v", r.URL.RawQuery)
+		}
+		if v := r.URL.Query().Get("query"); v != "up" {
+			t.Errorf("Query is overridden by extra parameters: %v", r.URL.RawQuery)
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	conf := NewClientConfig(server.URL)
+	conf.TenantID = "team-a"
+	conf.Headers = map[string]string{"X-Custom": "foo"}
+	conf.Params = map[string]string{"partial_response": "true", "query": "bad"}
+	client, err := NewRestClientWithConfig(conf)
+	if err != nil {
+		t.Errorf("Failed to create rest client: %v", err)
+		return
+	}
+
+	collector := NewWarningCollector()
+	ctx := WithWarningCollector(context.Background(), collector)
+
+	input := NewBasicInput()
+	input.SetQuery("up")
+	if _, err := client.WithContext(ctx).GetMetrics(input); err != nil {
+		t.Errorf("Failed to get metrics: %v", err)
+		return
+	}
+
+	// the same warning of another query is ignored
+	client.WithContext(ctx).GetMetrics(input)
+	if warnings := collector.Warnings(); len(warnings) != 1 {
+		t.Errorf("Wrong warnings: %v", warnings)
+	}
+}
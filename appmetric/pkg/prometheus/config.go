@@ -0,0 +1,244 @@
+package prometheus
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTenantHeader = "X-Scope-OrgID"
+
+	DefaultMaxRetries       = 2
+	DefaultBackoff          = "500ms"
+	DefaultMaxBackoff       = "5s"
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = "30s"
+)
+
+// ClientConfig is the configuration to access a prometheus server
+type ClientConfig struct {
+	Address string `json:"address"`
+
+	// marks the cluster or scope of the server; the servers with the same label are HA replicas
+	Label string `json:"label,omitempty"`
+
+	// basic auth
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// the token file is read for every request, so that the token can be rotated
+	BearerTokenFile string `json:"bearerTokenFile,omitempty"`
+
+	TLS TLSConfig `json:"tls,omitempty"`
+
+	// the tenant of a multi-tenant query frontend (Cortex/Thanos/Mimir), sent in the TenantHeader
+	TenantID     string `json:"tenantID,omitempty"`
+	TenantHeader string `json:"tenantHeader,omitempty"`
+
+	// static headers sent with every request
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// extra parameters of every query, e.g., dedup=true or partial_response=true
+	Params map[string]string `json:"params,omitempty"`
+
+	Retry   RetryConfig   `json:"retry,omitempty"`
+	Breaker BreakerConfig `json:"breaker,omitempty"`
+}
+
+// RetryConfig decides how the queries failed by transient errors (network errors, HTTP 429 and 5xx) are retried
+type RetryConfig struct {
+	// 0 to disable retry
+	MaxRetries int `json:"maxRetries"`
+
+	// the backoff before the first retry, e.g., "500ms"; it is doubled for every retry up to MaxBackoff.
+	// A random jitter is applied, and a longer Retry-After of the server is respected, up to MaxBackoff.
+	Backoff    string `json:"backoff,omitempty"`
+	MaxBackoff string `json:"maxBackoff,omitempty"`
+}
+
+// BreakerConfig decides when the circuit breaker of a prometheus server opens:
+// after Threshold consecutive failed queries, the queries fail fast for Cooldown;
+// then a trial query is sent, and the breaker is closed if it succeeds.
+type BreakerConfig struct {
+	// 0 to disable the circuit breaker
+	Threshold int    `json:"threshold"`
+	Cooldown  string `json:"cooldown,omitempty"`
+}
+
+// TLSConfig is the configuration of the https connection to the prometheus server
+type TLSConfig struct {
+	// the CA bundle to verify the server certificate; the system CAs are used if not set
+	CAFile string `json:"caFile,omitempty"`
+
+	// the client certificate and key
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+
+	// the name to verify the server certificate, if it differs from the host of the address
+	ServerName string `json:"serverName,omitempty"`
+
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// NewClientConfig creates the config for the server address, with the default settings:
+// the certificate of the https server is verified by the system CAs.
+func NewClientConfig(address string) *ClientConfig {
+	return &ClientConfig{
+		Address:      address,
+		TenantHeader: DefaultTenantHeader,
+		Retry: RetryConfig{
+			MaxRetries: DefaultMaxRetries,
+			Backoff:    DefaultBackoff,
+			MaxBackoff: DefaultMaxBackoff,
+		},
+		Breaker: BreakerConfig{
+			Threshold: DefaultBreakerThreshold,
+			Cooldown:  DefaultBreakerCooldown,
+		},
+	}
+}
+
+// UnmarshalJSON fills the default settings before decoding the config,
+// so that the settings not in the config, e.g., maxRetries, keep the default values
+func (c *RetryConfig) UnmarshalJSON(data []byte) error {
+	type plain RetryConfig
+	conf := plain(NewClientConfig("").Retry)
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return err
+	}
+
+	*c = RetryConfig(conf)
+	return nil
+}
+
+// UnmarshalJSON fills the default settings before decoding the config,
+// so that the breaker is disabled only by an explicit threshold of 0
+func (c *BreakerConfig) UnmarshalJSON(data []byte) error {
+	type plain BreakerConfig
+	conf := plain(NewClientConfig("").Breaker)
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return err
+	}
+
+	*c = BreakerConfig(conf)
+	return nil
+}
+
+// UnmarshalJSON fills the default settings before decoding the config
+func (c *ClientConfig) UnmarshalJSON(data []byte) error {
+	type plain ClientConfig
+	conf := plain(*NewClientConfig(""))
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return err
+	}
+
+	*c = ClientConfig(conf)
+	return nil
+}
+
+// parseDuration parses the duration, and returns the default one if it is not set
+func parseDuration(name, v, dv string) (time.Duration, error) {
+	if len(v) < 1 {
+		v = dv
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("Invalid %v: %v", name, v)
+	}
+	return d, nil
+}
+
+// newRetryPolicy creates the retryPolicy from the RetryConfig
+func (c *RetryConfig) newRetryPolicy() (*retryPolicy, error) {
+	if c.MaxRetries < 0 {
+		return nil, fmt.Errorf("Invalid maxRetries: %d", c.MaxRetries)
+	}
+
+	backoff, err := parseDuration("backoff", c.Backoff, DefaultBackoff)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBackoff, err := parseDuration("maxBackoff", c.MaxBackoff, DefaultMaxBackoff)
+	if err != nil {
+		return nil, err
+	}
+	if maxBackoff < backoff {
+		maxBackoff = backoff
+	}
+
+	return &retryPolicy{
+		maxRetries: c.MaxRetries,
+		backoff:    backoff,
+		maxBackoff: maxBackoff,
+	}, nil
+}
+
+// newCircuitBreaker creates the circuitBreaker of the server from the BreakerConfig
+func (c *BreakerConfig) newCircuitBreaker(host string) (*circuitBreaker, error) {
+	if c.Threshold < 0 {
+		return nil, fmt.Errorf("Invalid breaker threshold: %d", c.Threshold)
+	}
+
+	cooldown, err := parseDuration("breaker cooldown", c.Cooldown, DefaultBreakerCooldown)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCircuitBreaker(host, c.Threshold, cooldown), nil
+}
+
+// newTLSConfig creates the tls.Config from the files in the TLSConfig
+func (c *TLSConfig) newTLSConfig() (*tls.Config, error) {
+	result := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if len(c.CAFile) > 0 {
+		ca, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read CA file %v: %v", c.CAFile, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("No certificate is found in CA file %v", c.CAFile)
+		}
+		result.RootCAs = pool
+	}
+
+	if len(c.CertFile) > 0 || len(c.KeyFile) > 0 {
+		if len(c.CertFile) < 1 || len(c.KeyFile) < 1 {
+			return nil, fmt.Errorf("Both client cert file and key file should be set")
+		}
+
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load client cert %v and key %v: %v", c.CertFile, c.KeyFile, err)
+		}
+		result.Certificates = []tls.Certificate{cert}
+	}
+
+	return result, nil
+}
+
+// readBearerToken reads the token from the file
+func readBearerToken(fname string) (string, error) {
+	content, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read bearer token file %v: %v", fname, err)
+	}
+
+	token := strings.TrimSpace(string(content))
+	if len(token) < 1 {
+		return "", fmt.Errorf("Bearer token file %v is empty", fname)
+	}
+	return token, nil
+}
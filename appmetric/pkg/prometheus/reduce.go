@@ -0,0 +1,127 @@
+package prometheus
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/prometheus/common/model"
+)
+
+// The methods to reduce a series into a single value
+const (
+	ReduceAvg        = "avg"
+	ReduceMax        = "max"
+	ReducePercentile = "percentile"
+)
+
+// Reducer reduces the samples of a series into a single value
+type Reducer struct {
+	Method string
+	// only used by ReducePercentile, in [0, 100]
+	Percentile float64
+}
+
+func NewAvgReducer() *Reducer {
+	return &Reducer{Method: ReduceAvg}
+}
+
+func NewMaxReducer() *Reducer {
+	return &Reducer{Method: ReduceMax}
+}
+
+func NewPercentileReducer(p float64) *Reducer {
+	return &Reducer{
+		Method:     ReducePercentile,
+		Percentile: p,
+	}
+}
+
+// NewReducer creates a reducer by method name;
+// the percentile should be specified for the ReducePercentile method
+func NewReducer(method string, percentile float64) (*Reducer, error) {
+	switch method {
+	case ReduceAvg:
+		return NewAvgReducer(), nil
+	case ReduceMax:
+		return NewMaxReducer(), nil
+	case ReducePercentile:
+		if percentile < 0 || percentile > 100 {
+			return nil, fmt.Errorf("Invalid percentile: %v, vs [0, 100]", percentile)
+		}
+		return NewPercentileReducer(percentile), nil
+	}
+
+	return nil, fmt.Errorf("Unknown reduce method: %v", method)
+}
+
+// Reduce the samples into a single value; NaN samples are ignored.
+func (r *Reducer) Reduce(samples []model.SamplePair) (float64, error) {
+	values := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		v := float64(s.Value)
+		if math.IsNaN(v) {
+			continue
+		}
+		values = append(values, v)
+	}
+
+	if len(values) < 1 {
+		return 0, fmt.Errorf("No valid sample to reduce")
+	}
+
+	switch r.Method {
+	case ReduceAvg:
+		return average(values), nil
+	case ReduceMax:
+		return maximum(values), nil
+	case ReducePercentile:
+		return percentile(values, r.Percentile), nil
+	}
+
+	return 0, fmt.Errorf("Unknown reduce method: %v", r.Method)
+}
+
+func (r *Reducer) String() string {
+	if r.Method == ReducePercentile {
+		return fmt.Sprintf("%v(%v)", r.Method, r.Percentile)
+	}
+	return r.Method
+}
+
+func average(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func maximum(values []float64) float64 {
+	result := values[0]
+	for _, v := range values[1:] {
+		if v > result {
+			result = v
+		}
+	}
+	return result
+}
+
+// percentile with linear interpolation between the closest ranks
+func percentile(values []float64, p float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	rank := p / 100.0 * float64(len(sorted)-1)
+	low := int(math.Floor(rank))
+	high := int(math.Ceil(rank))
+	if low < 0 {
+		return sorted[0]
+	}
+	if high >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+
+	return sorted[low] + (rank-float64(low))*(sorted[high]-sorted[low])
+}
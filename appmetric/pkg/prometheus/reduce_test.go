@@ -0,0 +1,61 @@
+package prometheus
+
+import (
+	"math"
+	"testing"
+
+	"github.com/prometheus/common/model"
+)
+
+func newSamples(values ...float64) []model.SamplePair {
+	result := []model.SamplePair{}
+	for i, v := range values {
+		result = append(result, model.SamplePair{
+			Timestamp: model.TimeFromUnix(int64(i * 15)),
+			Value:     model.SampleValue(v),
+		})
+	}
+	return result
+}
+
+func TestReducer_Reduce(t *testing.T) {
+	samples := newSamples(4, 1, math.NaN(), 3, 2, 10)
+
+	tests := []struct {
+		reducer  *Reducer
+		expected float64
+	}{
+		{NewAvgReducer(), 4},
+		{NewMaxReducer(), 10},
+		{NewPercentileReducer(0), 1},
+		{NewPercentileReducer(50), 3},
+		{NewPercentileReducer(100), 10},
+		{NewPercentileReducer(95), 8.8},
+	}
+
+	for _, tt := range tests {
+		v, err := tt.reducer.Reduce(samples)
+		if err != nil {
+			t.Errorf("Failed to reduce by %v: %v", tt.reducer, err)
+			continue
+		}
+
+		if math.Abs(v-tt.expected) > 1e-6 {
+			t.Errorf("Wrong result of %v: %v Vs. %v", tt.reducer, v, tt.expected)
+		}
+	}
+}
+
+func TestReducer_Reduce_Fail(t *testing.T) {
+	if _, err := NewAvgReducer().Reduce(newSamples(math.NaN())); err == nil {
+		t.Errorf("Reduce should have failed without valid sample")
+	}
+
+	if _, err := NewReducer(ReducePercentile, 101); err == nil {
+		t.Errorf("NewReducer should have failed with percentile 101")
+	}
+
+	if _, err := NewReducer("min", 0); err == nil {
+		t.Errorf("NewReducer should have failed with unknown method")
+	}
+}
@@ -0,0 +1,138 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/prometheus/common/model"
+	"strings"
+	"sync"
+)
+
+// ReplicaClient queries a group of prometheus servers holding the same data, e.g., an HA pair.
+// The identical series (same labels) from different replicas are deduplicated:
+// the one from the first replica is kept.
+// The query fails only if all the replicas fail.
+type ReplicaClient struct {
+	replicas []*RestClient
+	ctx      context.Context
+}
+
+// ensure ReplicaClient implement the requisite interfaces
+var _ MetricClient = &ReplicaClient{}
+
+func NewReplicaClient(replicas []*RestClient) *ReplicaClient {
+	return &ReplicaClient{
+		replicas: replicas,
+	}
+}
+
+func (c *ReplicaClient) WithContext(ctx context.Context) MetricClient {
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+func (c *ReplicaClient) Health() []*EndpointHealth {
+	result := []*EndpointHealth{}
+	for _, r := range c.replicas {
+		result = append(result, r.Health()...)
+	}
+	return result
+}
+
+// the replica bound to the context of the ReplicaClient
+func (c *ReplicaClient) replica(i int) *RestClient {
+	if c.ctx == nil {
+		return c.replicas[i]
+	}
+
+	r := *c.replicas[i]
+	r.ctx = c.ctx
+	return &r
+}
+
+func (c *ReplicaClient) GetMetrics(input RequestInput) ([]MetricData, error) {
+	results := make([][]RawMetric, len(c.replicas))
+	err := c.queryAll(func(i int, r *RestClient) error {
+		resp, err := r.queryVector(input.GetQuery())
+		results[i] = resp
+		return err
+	})
+	if err != nil {
+		return []MetricData{}, err
+	}
+
+	resp := []RawMetric{}
+	seen := make(map[uint64]struct{})
+	for _, result := range results {
+		for _, m := range result {
+			sig := model.LabelsToSignature(m.Labels)
+			if _, exist := seen[sig]; exist {
+				continue
+			}
+			seen[sig] = struct{}{}
+			resp = append(resp, m)
+		}
+	}
+
+	return parseVector(input, resp), nil
+}
+
+func (c *ReplicaClient) GetRangeMetrics(input RangeRequestInput) ([]MetricData, error) {
+	start, end, step := input.GetRange()
+	results := make([][]RawSeries, len(c.replicas))
+	err := c.queryAll(func(i int, r *RestClient) error {
+		resp, err := r.queryMatrix(input.GetQuery(), start, end, step)
+		results[i] = resp
+		return err
+	})
+	if err != nil {
+		return []MetricData{}, err
+	}
+
+	resp := []RawSeries{}
+	seen := make(map[uint64]struct{})
+	for _, result := range results {
+		for _, s := range result {
+			sig := model.LabelsToSignature(s.Labels)
+			if _, exist := seen[sig]; exist {
+				continue
+			}
+			seen[sig] = struct{}{}
+			resp = append(resp, s)
+		}
+	}
+
+	return parseMatrix(input, resp), nil
+}
+
+// queryAll runs the query against all the replicas in parallel;
+// it fails only if all the replicas fail.
+func (c *ReplicaClient) queryAll(query func(i int, r *RestClient) error) error {
+	errs := make([]error, len(c.replicas))
+
+	var wg sync.WaitGroup
+	for i := range c.replicas {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = query(i, c.replica(i))
+		}(i)
+	}
+	wg.Wait()
+
+	msgs := []string{}
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		glog.Warningf("Failed to query replica %v: %v", c.replicas[i].host, err)
+		msgs = append(msgs, fmt.Sprintf("%v: %v", c.replicas[i].host, err))
+	}
+
+	if len(msgs) == len(c.replicas) {
+		return fmt.Errorf("all replicas failed: %v", strings.Join(msgs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newReplicaServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+}
+
+func TestReplicaClient_GetMetrics(t *testing.T) {
+	server1 := newReplicaServer(`{"status":"success","data":{"resultType":"vector","result":[
+		{"metric":{"instance":"10.0.2.3:9121"},"value":[1530000000,"1"]},
+		{"metric":{"instance":"10.0.3.2:9121"},"value":[1530000000,"2"]}]}}`)
+	defer server1.Close()
+
+	// the replica misses a series, and reports a slightly different value for the same series
+	server2 := newReplicaServer(`{"status":"success","data":{"resultType":"vector","result":[
+		{"metric":{"instance":"10.0.2.3:9121"},"value":[1530000000,"1.5"]},
+		{"metric":{"instance":"10.0.4.1:9121"},"value":[1530000000,"3"]}]}}`)
+	defer server2.Close()
+
+	// the replica is down
+	server3 := newReplicaServer(`not json`)
+	defer server3.Close()
+
+	replicas := []*RestClient{}
+	for _, url := range []string{server1.URL, server2.URL, server3.URL} {
+		client, err := NewRestClient(url)
+		if err != nil {
+			t.Errorf("Failed to create rest client: %v", err)
+			return
+		}
+		replicas = append(replicas, client)
+	}
+
+	input := NewBasicInput()
+	input.SetQuery("rate(redis_commands_processed_total[1m])")
+	result, err := NewReplicaClient(replicas).GetMetrics(input)
+	if err != nil {
+		t.Errorf("Failed to get metrics: %v", err)
+		return
+	}
+
+	expected := map[string]float64{
+		"10.0.2.3:9121": 1,
+		"10.0.3.2:9121": 2,
+		"10.0.4.1:9121": 3,
+	}
+	if len(result) != len(expected) {
+		t.Errorf("Wrong number of series: %d Vs. %d", len(result), len(expected))
+	}
+
+	for _, d := range result {
+		m := d.(*BasicMetricData)
+		if v := expected[m.Labels["instance"]]; v != m.GetValue() {
+			t.Errorf("Wrong value for %v: %v Vs. %v", m.Labels["instance"], m.GetValue(), v)
+		}
+	}
+}
+
+func TestReplicaClient_AllFailed(t *testing.T) {
+	server := newReplicaServer(`{"status":"error","errorType":"bad_data","error":"parse error"}`)
+	defer server.Close()
+
+	client, err := NewRestClient(server.URL)
+	if err != nil {
+		t.Errorf("Failed to create rest client: %v", err)
+		return
+	}
+
+	input := NewBasicInput()
+	input.SetQuery("up")
+	if _, err := NewReplicaClient([]*RestClient{client, client}).GetMetrics(input); err == nil {
+		t.Errorf("Expect error if all replicas failed")
+	}
+}
@@ -0,0 +1,89 @@
+package prometheus
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryPolicy decides the backoff between the retries of the queries failed by transient errors
+type retryPolicy struct {
+	maxRetries int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// getBackoff returns the backoff before the n-th (from 0) retry:
+// the exponential backoff with a random jitter in [backoff/2, backoff),
+// or the Retry-After of the server, if it is longer, capped by maxBackoff.
+func (p *retryPolicy) getBackoff(n int, retryAfter time.Duration) time.Duration {
+	backoff := p.backoff
+	for i := 0; i < n && backoff < p.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.maxBackoff {
+		backoff = p.maxBackoff
+	}
+
+	if half := int64(backoff / 2); half > 0 {
+		backoff = time.Duration(half + rand.Int63n(half))
+	}
+
+	// a long Retry-After would stall the getter until its deadline
+	if retryAfter > p.maxBackoff {
+		retryAfter = p.maxBackoff
+	}
+	if retryAfter > backoff {
+		return retryAfter
+	}
+	return backoff
+}
+
+// queryError is an error of a query: the transient errors are retried, and are counted by the circuit breaker
+type queryError struct {
+	msg       string
+	transient bool
+
+	// the Retry-After of the server, if any
+	retryAfter time.Duration
+}
+
+func (e *queryError) Error() string {
+	return e.msg
+}
+
+func isTransient(err error) bool {
+	qerr, ok := err.(*queryError)
+	return ok && qerr.transient
+}
+
+func getRetryAfter(err error) time.Duration {
+	if qerr, ok := err.(*queryError); ok {
+		return qerr.retryAfter
+	}
+	return 0
+}
+
+// isTransientStatus returns whether the http status is worth a retry
+func isTransientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
+
+// parseRetryAfter parses the Retry-After header, in either seconds or a http date
+func parseRetryAfter(v string) time.Duration {
+	if len(v) < 1 {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,165 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const emptyVectorBody = `{"status":"success","data":{"resultType":"vector","result":[]}}`
+
+func newFlakyServer(failures int32, code int, body string) (*httptest.Server, *int32) {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) <= failures {
+			w.WriteHeader(code)
+			w.Write([]byte(body))
+			return
+		}
+		w.Write([]byte(emptyVectorBody))
+	}))
+	return server, &count
+}
+
+func newTestClient(t *testing.T, address string, maxRetries, threshold int) *RestClient {
+	conf := NewClientConfig(address)
+	conf.Retry = RetryConfig{MaxRetries: maxRetries, Backoff: "1ms", MaxBackoff: "4ms"}
+	conf.Breaker = BreakerConfig{Threshold: threshold, Cooldown: "100ms"}
+	client, err := NewRestClientWithConfig(conf)
+	if err != nil {
+		t.Fatalf("Failed to create rest client: %v", err)
+	}
+	return client
+}
+
+func TestRestClient_Retry(t *testing.T) {
+	server, count := newFlakyServer(2, http.StatusServiceUnavailable, `{"status":"error","error":"unavailable"}`)
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, 2, 0)
+	if _, err := client.Query("up"); err != nil {
+		t.Errorf("Failed to query with retries: %v", err)
+	}
+
+	if *count != 3 {
+		t.Errorf("Wrong number of attempts: %d Vs. 3", *count)
+	}
+}
+
+func TestRestClient_Retry_NotTransient(t *testing.T) {
+	server, count := newFlakyServer(1, http.StatusBadRequest, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, 2, 0)
+	if _, err := client.Query("up{"); err == nil || err.Error() != "parse error" {
+		t.Errorf("Wrong error of a bad query: %v", err)
+	}
+
+	if *count != 1 {
+		t.Errorf("Bad query is retried: %d attempts", *count)
+	}
+}
+
+func TestRestClient_NonJSON(t *testing.T) {
+	server, _ := newFlakyServer(10, http.StatusBadGateway, `<html><body><h1>502 Bad Gateway</h1></body></html>`)
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, 0, 0)
+	_, err := client.Query("up")
+	if err == nil || !strings.Contains(err.Error(), "502 Bad Gateway") || !strings.Contains(err.Error(), "not a JSON") {
+		t.Errorf("Unclear error of a non-JSON response: %v", err)
+	}
+}
+
+func TestRestClient_CircuitBreaker(t *testing.T) {
+	server, count := newFlakyServer(2, http.StatusBadGateway, `bad gateway`)
+	defer server.Close()
+
+	client := newTestClient(t, server.URL, 0, 2)
+	for i := 0; i < 3; i++ {
+		if _, err := client.Query("up"); err == nil {
+			t.Errorf("Query %d should fail", i)
+		}
+	}
+
+	// the third query fails fast
+	if *count != 2 {
+		t.Errorf("Wrong number of requests: %d Vs. 2", *count)
+	}
+	if h := client.Health()[0]; h.State != BreakerOpen || h.ConsecutiveFailures != 2 {
+		t.Errorf("Wrong health of open breaker: %+v", h)
+	}
+
+	// the trial query after the cooldown closes the breaker
+	time.Sleep(150 * time.Millisecond)
+	if _, err := client.Query("up"); err != nil {
+		t.Errorf("Trial query failed: %v", err)
+	}
+	if h := client.Health()[0]; h.State != BreakerClosed || h.ConsecutiveFailures != 0 {
+		t.Errorf("Wrong health of closed breaker: %+v", h)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("3"); d != 3*time.Second {
+		t.Errorf("Wrong Retry-After in seconds: %v", d)
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(date); d < 50*time.Second || d > time.Minute {
+		t.Errorf("Wrong Retry-After in http date: %v", d)
+	}
+
+	if d := parseRetryAfter("soon"); d != 0 {
+		t.Errorf("Wrong invalid Retry-After: %v", d)
+	}
+}
+
+func TestRetryPolicy_GetBackoff(t *testing.T) {
+	p := &retryPolicy{maxRetries: 5, backoff: 100 * time.Millisecond, maxBackoff: time.Second}
+
+	for i, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max = max * time.Millisecond
+		if d := p.getBackoff(i, 0); d < max/2 || d >= max {
+			t.Errorf("Wrong backoff of retry %d: %v, expect [%v, %v)", i, d, max/2, max)
+		}
+	}
+
+	if d := p.getBackoff(0, 500*time.Millisecond); d != 500*time.Millisecond {
+		t.Errorf("Retry-After is not respected: %v", d)
+	}
+
+	// the Retry-After longer than maxBackoff is capped
+	if d := p.getBackoff(0, time.Hour); d != time.Second {
+		t.Errorf("Retry-After is not capped: %v", d)
+	}
+}
+
+func TestRetryAndBreakerConfig_UnmarshalJSON(t *testing.T) {
+	// the settings not in the config keep the default values
+	conf := &struct {
+		Retry   *RetryConfig   `json:"retry"`
+		Breaker *BreakerConfig `json:"breaker"`
+	}{}
+	if err := json.Unmarshal([]byte(`{"retry":{"backoff":"1s"},"breaker":{"cooldown":"1m"}}`), conf); err != nil {
+		t.Fatalf("Failed to decode the config: %v", err)
+	}
+	if r := conf.Retry; r.MaxRetries != DefaultMaxRetries || r.Backoff != "1s" || r.MaxBackoff != DefaultMaxBackoff {
+		t.Errorf("Wrong retry config: %+v", r)
+	}
+	if b := conf.Breaker; b.Threshold != DefaultBreakerThreshold || b.Cooldown != "1m" {
+		t.Errorf("Wrong breaker config: %+v", b)
+	}
+
+	// the breaker is disabled by an explicit threshold of 0
+	if err := json.Unmarshal([]byte(`{"breaker":{"threshold":0}}`), conf); err != nil {
+		t.Fatalf("Failed to decode the config: %v", err)
+	}
+	if b := conf.Breaker; b.Threshold != 0 || b.Cooldown != DefaultBreakerCooldown {
+		t.Errorf("Wrong disabled breaker config: %+v", b)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/prometheus/common/model"
 	"math"
+	"time"
 )
 
 // for internal use only
@@ -15,6 +16,8 @@ type promeResponse struct {
 	Data      *RawData `json:"data,omitempty"`
 	ErrorType string   `json:"errorType,omitempty"`
 	Error     string   `json:"error,omitempty"`
+	// e.g., the partial response of a query frontend
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 type RawData struct {
@@ -28,6 +31,12 @@ type RawMetric struct {
 	Value  model.SamplePair  `json:"value"`
 }
 
+// RawSeries the raw series from a Prometheus range query: its labels and a list of time/value pairs
+type RawSeries struct {
+	Labels map[string]string  `json:"metric"`
+	Values []model.SamplePair `json:"values"`
+}
+
 // MetricData : interface to transform the RawMetric to customer defined data structure
 type MetricData interface {
 	GetValue() float64
@@ -39,6 +48,14 @@ type RequestInput interface {
 	Parse(metric *RawMetric) (MetricData, error)
 }
 
+// RangeRequestInput : interface for customer defined range query generator, and RawSeries parser.
+// The parser usually reduces the series into a single value, e.g., its average or peak.
+type RangeRequestInput interface {
+	GetQuery() string
+	GetRange() (start, end time.Time, step time.Duration)
+	ParseSeries(series *RawSeries) (MetricData, error)
+}
+
 // -----------------------------------------------------------
 // an example implementation of RequestInput and MetricData
 type BasicMetricData struct {
@@ -104,3 +121,58 @@ func (d *BasicMetricData) String() string {
 	}
 	return buffer.String()
 }
+
+// -----------------------------------------------------------
+// an example implementation of RangeRequestInput:
+// it queries the last 'window' of samples, and reduces each series by the reducer
+type BasicRangeInput struct {
+	query   string
+	window  time.Duration
+	step    time.Duration
+	reducer *Reducer
+}
+
+func NewBasicRangeInput(window, step time.Duration, reducer *Reducer) *BasicRangeInput {
+	return &BasicRangeInput{
+		window:  window,
+		step:    step,
+		reducer: reducer,
+	}
+}
+
+func (input *BasicRangeInput) GetQuery() string {
+	return input.query
+}
+
+func (input *BasicRangeInput) SetQuery(q string) {
+	input.query = q
+}
+
+func (input *BasicRangeInput) GetRange() (time.Time, time.Time, time.Duration) {
+	end := time.Now()
+	return end.Add(-input.window), end, input.step
+}
+
+func (input *BasicRangeInput) ParseSeries(s *RawSeries) (MetricData, error) {
+	d := NewBasicMetricData()
+	if err := d.ParseSeries(s, input.reducer); err != nil {
+		glog.Errorf("Failed to parse raw series: %v", err)
+		return nil, err
+	}
+	return d, nil
+}
+
+// ParseSeries copies the labels of the series, and reduces its samples into the value
+func (d *BasicMetricData) ParseSeries(s *RawSeries, reducer *Reducer) error {
+	for k, v := range s.Labels {
+		d.Labels[k] = v
+	}
+
+	value, err := reducer.Reduce(s.Values)
+	if err != nil {
+		return err
+	}
+
+	d.Value = value
+	return nil
+}
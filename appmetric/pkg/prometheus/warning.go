@@ -0,0 +1,63 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type warningCollectorKey struct{}
+
+// WarningCollector collects the warnings returned by the prometheus API,
+// e.g., the partial response of a query frontend when some of its stores are down.
+type WarningCollector struct {
+	warnings []string
+	seen     map[string]struct{}
+	lock     sync.Mutex
+}
+
+func NewWarningCollector() *WarningCollector {
+	return &WarningCollector{
+		warnings: []string{},
+		seen:     make(map[string]struct{}),
+	}
+}
+
+// WithWarningCollector returns a context carrying the collector:
+// the warnings of the queries bound to the context (by WithContext) are added to the collector.
+func WithWarningCollector(ctx context.Context, collector *WarningCollector) context.Context {
+	return context.WithValue(ctx, warningCollectorKey{}, collector)
+}
+
+func warningCollectorFrom(ctx context.Context) *WarningCollector {
+	if ctx == nil {
+		return nil
+	}
+
+	collector, _ := ctx.Value(warningCollectorKey{}).(*WarningCollector)
+	return collector
+}
+
+// Add adds the warnings from the host; the duplicated warnings are ignored.
+func (w *WarningCollector) Add(host string, warnings []string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, warning := range warnings {
+		msg := fmt.Sprintf("%v: %v", host, warning)
+		if _, exist := w.seen[msg]; exist {
+			continue
+		}
+		w.seen[msg] = struct{}{}
+		w.warnings = append(w.warnings, msg)
+	}
+}
+
+func (w *WarningCollector) Warnings() []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	result := make([]string, len(w.warnings))
+	copy(result, w.warnings)
+	return result
+}
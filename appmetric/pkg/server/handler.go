@@ -8,8 +8,11 @@ import (
 	"html/template"
 	"io"
 	"net/http"
+	"strconv"
 
+	"github.com/turbonomic/prometurbo/appmetric/pkg/alligator"
 	"github.com/turbonomic/prometurbo/appmetric/pkg/inter"
+	"github.com/turbonomic/prometurbo/appmetric/pkg/prometheus"
 	"github.com/turbonomic/prometurbo/appmetric/pkg/util"
 )
 
@@ -154,6 +157,10 @@ func (s *MetricServer) sendMetrics(metrics []*inter.EntityMetric, w http.Respons
 	resp.SetStatus(0, "Success")
 	resp.SetMetrics(metrics)
 
+	s.sendResponse(resp, w, r)
+}
+
+func (s *MetricServer) sendResponse(resp *inter.MetricResponse, w http.ResponseWriter, r *http.Request) {
 	//3. marshal to json
 	result, err := json.Marshal(resp)
 	if err != nil {
@@ -171,25 +178,31 @@ func (s *MetricServer) sendMetrics(metrics []*inter.EntityMetric, w http.Respons
 	return
 }
 
+// whether the request asks for fresh metrics, e.g., /pod/metrics?refresh=true
+func needRefresh(r *http.Request) bool {
+	refresh, err := strconv.ParseBool(r.URL.Query().Get(refreshParam))
+	return err == nil && refresh
+}
+
 func (s *MetricServer) handleAppMetric(w http.ResponseWriter, r *http.Request) {
 	//1. get metrics
-	metrics, err := s.appClient.GetEntityMetrics()
+	resp, err := s.appClient.GetEntityMetrics(needRefresh(r))
 	if err != nil {
 		glog.Errorf("Failed to get Application Metrics: %v", err)
 		s.sendFailure(w, r)
 		return
 	}
 
-	glog.V(3).Infof("App metrics num: %v", len(metrics))
+	glog.V(3).Infof("App metrics num: %v", len(resp.Data))
 
 	//2. put metrics to response
-	s.sendMetrics(metrics, w, r)
+	s.sendResponse(resp, w, r)
 	return
 }
 
 func (s *MetricServer) handleServiceMetric(w http.ResponseWriter, r *http.Request) {
 	//1. get metrics
-	metrics, err := s.vappClient.GetEntityMetrics()
+	resp, err := s.vappClient.GetEntityMetrics(needRefresh(r))
 	if err != nil {
 		glog.Errorf("Failed to get Application Metrics: %v", err)
 		s.sendFailure(w, r)
@@ -197,7 +210,7 @@ func (s *MetricServer) handleServiceMetric(w http.ResponseWriter, r *http.Reques
 	}
 
 	//2. put metrics to response
-	s.sendMetrics(metrics, w, r)
+	s.sendResponse(resp, w, r)
 }
 
 func (s *MetricServer) handleFakeMetric(w http.ResponseWriter, r *http.Request) {
@@ -207,3 +220,45 @@ func (s *MetricServer) handleFakeMetric(w http.ResponseWriter, r *http.Request)
 	s.sendMetrics(metrics, w, r)
 	glog.V(3).Infof("fake metric service finish: %d", len(metrics))
 }
+
+// healthResponse is the health of the prometheus servers
+type healthResponse struct {
+	// "ok", or "degraded" if the circuit breaker of any server is not closed
+	Status    string                       `json:"status"`
+	Endpoints []*prometheus.EndpointHealth `json:"endpoints"`
+}
+
+func (s *MetricServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	resp := &healthResponse{
+		Status:    "ok",
+		Endpoints: []*prometheus.EndpointHealth{},
+	}
+
+	// the app and service getters may query the same servers
+	seen := make(map[string]struct{})
+	for _, scraper := range []*alligator.Scraper{s.appClient, s.vappClient} {
+		for _, h := range scraper.Health() {
+			key := h.Source + "@" + h.Address
+			if _, exist := seen[key]; exist {
+				continue
+			}
+			seen[key] = struct{}{}
+
+			if h.State != prometheus.BreakerClosed {
+				resp.Status = "degraded"
+			}
+			resp.Endpoints = append(resp.Endpoints, h)
+		}
+	}
+
+	result, err := json.Marshal(resp)
+	if err != nil {
+		glog.Errorf("Failed to marshal json: %v", err)
+		s.sendFailure(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(result)
+}
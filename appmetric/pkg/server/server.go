@@ -16,17 +16,21 @@ type MetricServer struct {
 	ip   string
 	host string
 
-	appClient  *alligator.Alligator
-	vappClient *alligator.Alligator
+	appClient  *alligator.Scraper
+	vappClient *alligator.Scraper
 }
 
 const (
 	appMetricPath     = "/pod/metrics"
 	serviceMetricPath = "/service/metrics"
 	fakeMetricPath    = "/fake/metrics"
+	healthPath        = "/health"
+
+	// query parameter to scrape the metrics instead of serving the snapshot
+	refreshParam = "refresh"
 )
 
-func NewMetricServer(port int, appClient, vappclient *alligator.Alligator) *MetricServer {
+func NewMetricServer(port int, appClient, vappclient *alligator.Scraper) *MetricServer {
 	ip, err := util.ExternalIP()
 	if err != nil {
 		glog.Errorf("Failed to get server IP: %v", err)
@@ -83,9 +87,10 @@ func (s *MetricServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//if strings.EqualFold(path, "/health") {
-	//	s.handleHealth(w, r)
-	//}
+	if strings.EqualFold(path, healthPath) {
+		s.handleHealth(w, r)
+		return
+	}
 
 	s.handleWelcome(path, w, r)
 	return
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/golang/glog"
+	"github.com/turbonomic/prometurbo/prometurbo/pkg/discovery/dtofactory"
 	"github.com/turbonomic/turbo-go-sdk/pkg/service"
 	"io/ioutil"
 )
@@ -18,6 +19,16 @@ type PrometurboConf struct {
 	Communicator           *service.TurboCommunicationConfig `json:"communicationConfig,omitempty"`
 	TargetConf             *PrometurboTargetConf             `json:"prometurboTargetConfig,omitempty"`
 	MetricExporterEndpoint string                            `json:"metricExporterEndpoint,omitempty"`
+
+	// more endpoints to query, e.g., the service metrics of appMetric for the dependencies between the services
+	MetricExporterEndpoints []string `json:"metricExporterEndpoints,omitempty"`
+
+	// the attributes of the entity metrics, e.g., "error_rate_5xx", mapped to commodities or properties
+	AttributeMappings []*dtofactory.AttributeMapping `json:"attributeMappings,omitempty"`
+
+	// report the containers and pods as discovered entities for the clusters without kubeturbo,
+	// instead of stitching them with the ones discovered by kubeturbo
+	DiscoverContainers bool `json:"discoverContainers,omitempty"`
 }
 
 type PrometurboTargetConf struct {
@@ -46,6 +57,12 @@ func NewPrometurboConf(configFilePath string) (*PrometurboConf, error) {
 		return nil, fmt.Errorf("Unable to read the target config from %s", configFilePath)
 	}
 
+	for _, m := range config.AttributeMappings {
+		if err := m.Validate(); err != nil {
+			return nil, fmt.Errorf("Invalid attribute mapping in %s: %v", configFilePath, err)
+		}
+	}
+
 	return config, nil
 }
 
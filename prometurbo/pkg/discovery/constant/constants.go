@@ -20,6 +20,18 @@ const (
 	TPSCap     = 20.0
 	LatencyCap = 500.0 //millisec
 
+	// The default capacities of the database commodities, if not reported by the exporter
+	ConnectionCap     = 10000.0
+	DBCacheHitRateCap = 100.0 //percentage
+
+	// The default capacities of the JVM commodities; the capacity of HEAP is the max heap reported by the exporter
+	CollectionTimeCap = 100.0 //percentage
+	ThreadsCap        = 1000.0
+
+	// The default capacities of the VM throughputs; the capacities of VCPU and VMEM are reported by the exporter
+	NetThroughputCap = 125000.0 //KB/s, 1Gbps
+	IOThroughputCap  = 512000.0 //KB/s
+
 	// The default namespace of entity property
 	DefaultPropertyNamespace = "DEFAULT"
 
@@ -27,18 +39,66 @@ const (
 	StitchingAttr string = "IP"
 
 	VAppPrefix = "vApp-"
+
+	// The label of the entity IP, reported by the metric exporter;
+	// the UID may be prefixed by the source of the metrics if the exporter queries several prometheus servers
+	IPLabel = "ip"
 )
 
 var EntityTypeMap = map[proto.EntityDTO_EntityType]struct{}{
-	proto.EntityDTO_APPLICATION: {},
+	proto.EntityDTO_APPLICATION:          {},
+	proto.EntityDTO_VIRTUAL_APPLICATION:  {},
+	proto.EntityDTO_BUSINESS_APPLICATION: {},
+	proto.EntityDTO_VIRTUAL_MACHINE:      {},
+	proto.EntityDTO_CONTAINER:            {},
+	proto.EntityDTO_CONTAINER_POD:        {},
+}
+
+// The entities discovered by other probes, e.g., the hypervisor probes or kubeturbo, whose commodities have no keys;
+// only the used values of their commodities are patched by the stitching
+var ResourceEntityTypeMap = map[proto.EntityDTO_EntityType]struct{}{
+	proto.EntityDTO_VIRTUAL_MACHINE: {},
+	proto.EntityDTO_CONTAINER:       {},
+	proto.EntityDTO_CONTAINER_POD:   {},
+}
+
+// The containers and pods, stitched with the ones discovered by kubeturbo,
+// or reported as discovered entities for the clusters without kubeturbo if enabled
+var ContainerEntityTypeMap = map[proto.EntityDTO_EntityType]struct{}{
+	proto.EntityDTO_CONTAINER:     {},
+	proto.EntityDTO_CONTAINER_POD: {},
+}
+
+// The commodities sold by the applications, with 0 if not reported by the exporter;
+// the business applications, e.g., the endpoints probed by blackbox_exporter, sell only the reported ones
+var DefaultCommodityTypeMap = map[proto.EntityDTO_EntityType][]proto.CommodityDTO_CommodityType{
+	proto.EntityDTO_APPLICATION:         {proto.CommodityDTO_TRANSACTION, proto.CommodityDTO_RESPONSE_TIME},
+	proto.EntityDTO_VIRTUAL_APPLICATION: {proto.CommodityDTO_TRANSACTION, proto.CommodityDTO_RESPONSE_TIME},
 }
 
 var CommodityTypeMap = map[proto.CommodityDTO_CommodityType]struct{}{
-	proto.CommodityDTO_TRANSACTION:   {},
-	proto.CommodityDTO_RESPONSE_TIME: {},
+	proto.CommodityDTO_TRANSACTION:       {},
+	proto.CommodityDTO_RESPONSE_TIME:     {},
+	proto.CommodityDTO_DB_MEM:            {},
+	proto.CommodityDTO_CONNECTION:        {},
+	proto.CommodityDTO_DB_CACHE_HIT_RATE: {},
+	proto.CommodityDTO_HEAP:              {},
+	proto.CommodityDTO_COLLECTION_TIME:   {},
+	proto.CommodityDTO_THREADS:           {},
+	proto.CommodityDTO_VCPU:              {},
+	proto.CommodityDTO_VMEM:              {},
+	proto.CommodityDTO_NET_THROUGHPUT:    {},
+	proto.CommodityDTO_IO_THROUGHPUT:     {},
 }
 
+// The default capacities; the commodities without the default, e.g., DB_MEM, need the capacities from the exporter
 var CommodityCapMap = map[proto.CommodityDTO_CommodityType]float64{
-	proto.CommodityDTO_TRANSACTION:   TPSCap,
-	proto.CommodityDTO_RESPONSE_TIME: LatencyCap,
+	proto.CommodityDTO_TRANSACTION:       TPSCap,
+	proto.CommodityDTO_RESPONSE_TIME:     LatencyCap,
+	proto.CommodityDTO_CONNECTION:        ConnectionCap,
+	proto.CommodityDTO_DB_CACHE_HIT_RATE: DBCacheHitRateCap,
+	proto.CommodityDTO_COLLECTION_TIME:   CollectionTimeCap,
+	proto.CommodityDTO_THREADS:           ThreadsCap,
+	proto.CommodityDTO_NET_THROUGHPUT:    NetThroughputCap,
+	proto.CommodityDTO_IO_THROUGHPUT:     IOThroughputCap,
 }
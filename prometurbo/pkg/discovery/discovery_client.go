@@ -15,6 +15,12 @@ type P8sDiscoveryClient struct {
 	targetAddr      string
 	scope           string
 	metricExporters []exporter.MetricExporter
+
+	// the attributes of the entity metrics mapped to commodities or properties
+	attributeMappings []*dtofactory.AttributeMapping
+
+	// whether the containers and pods are reported as discovered entities, instead of stitched with the ones of kubeturbo
+	discoverContainers bool
 }
 
 func NewDiscoveryClient(targetAddr, scope string, metricExporters []exporter.MetricExporter) *P8sDiscoveryClient {
@@ -25,6 +31,14 @@ func NewDiscoveryClient(targetAddr, scope string, metricExporters []exporter.Met
 	}
 }
 
+func (d *P8sDiscoveryClient) SetAttributeMappings(mappings []*dtofactory.AttributeMapping) {
+	d.attributeMappings = mappings
+}
+
+func (d *P8sDiscoveryClient) SetContainerDiscovery(discover bool) {
+	d.discoverContainers = discover
+}
+
 // Get the Account Values to create VMTTarget in the turbo server corresponding to this client
 func (d *P8sDiscoveryClient) GetAccountValues() *probe.TurboTargetInfo {
 	targetId := registration.TargetIdField
@@ -69,16 +83,20 @@ func (d *P8sDiscoveryClient) Validate(accountValues []*proto.AccountValue) (*pro
 func (d *P8sDiscoveryClient) Discover(accountValues []*proto.AccountValue) (*proto.DiscoveryResponse, error) {
 	glog.V(2).Infof("Discovering the target %s", accountValues)
 	var entities []*proto.EntityDTO
+	var edges []*exporter.Edge
+	var warnings []*proto.ErrorDTO
 	allExportersFailed := true
 
 	for _, metricExporter := range d.metricExporters {
-		dtos, err := d.buildEntities(metricExporter)
+		dtos, result, err := d.buildEntities(metricExporter)
 		if err != nil {
 			glog.Errorf("Error while querying metrics exporter %v: %v", metricExporter, err)
 			continue
 		}
 		allExportersFailed = false
 		entities = append(entities, dtos...)
+		edges = append(edges, result.Edges...)
+		warnings = append(warnings, result.Warnings...)
 
 		glog.V(4).Infof("Entities built from exporter %v: %v", metricExporter, dtos)
 	}
@@ -88,24 +106,31 @@ func (d *P8sDiscoveryClient) Discover(accountValues []*proto.AccountValue) (*pro
 		return d.failDiscovery(), nil
 	}
 
+	// The edges may connect the entities from different exporters
+	dtofactory.NewEdgeBuilder(d.scope, edges).Build(entities)
+
 	discoveryResponse := &proto.DiscoveryResponse{
 		EntityDTO: entities,
+		ErrorDTO:  warnings,
 	}
 
 	return discoveryResponse, nil
 }
 
-func (d *P8sDiscoveryClient) buildEntities(metricExporter exporter.MetricExporter) ([]*proto.EntityDTO, error) {
+func (d *P8sDiscoveryClient) buildEntities(metricExporter exporter.MetricExporter) ([]*proto.EntityDTO, *exporter.QueryResult, error) {
 	var entities []*proto.EntityDTO
 
-	metrics, err := metricExporter.Query()
+	result, err := metricExporter.Query()
 	if err != nil {
 		glog.Errorf("Error while querying metrics exporter: %v", err)
-		return nil, err
+		return nil, nil, err
 	}
 
-	for _, metric := range metrics {
-		dtos, err := dtofactory.NewEntityBuilder(d.scope, metric).Build()
+	for _, metric := range result.Metrics {
+		dtos, err := dtofactory.NewEntityBuilder(d.scope, metric).
+			WithAttributeMappings(d.attributeMappings).
+			WithContainerDiscovery(d.discoverContainers).
+			Build()
 		if err != nil {
 			glog.Errorf("Error building entity from metric %v: %s", metric, err)
 			continue
@@ -113,7 +138,7 @@ func (d *P8sDiscoveryClient) buildEntities(metricExporter exporter.MetricExporte
 		entities = append(entities, dtos...)
 	}
 
-	return entities, nil
+	return entities, result, nil
 }
 
 func (d *P8sDiscoveryClient) failDiscovery() *proto.DiscoveryResponse {
@@ -6,6 +6,7 @@ import (
 
 	"fmt"
 	"github.com/turbonomic/prometurbo/prometurbo/pkg/discovery/constant"
+	"github.com/turbonomic/prometurbo/prometurbo/pkg/discovery/dtofactory"
 	"github.com/turbonomic/prometurbo/prometurbo/pkg/discovery/exporter"
 	"github.com/turbonomic/turbo-go-sdk/pkg/builder"
 	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
@@ -114,13 +115,429 @@ func TestP8sDiscoveryClient_Discover_Query_Failed(t *testing.T) {
 	}
 }
 
+func TestP8sDiscoveryClient_Discover_Getter_Warnings(t *testing.T) {
+	severity := proto.ErrorDTO_WARNING
+	description := "Getter redis.app.metric (Redis) of metric exporter foo failed: timeout"
+	exporter1 := &mockExporter{
+		metrics: metrics[0:2],
+		warnings: []*proto.ErrorDTO{
+			{Severity: &severity, Description: &description},
+		},
+	}
+
+	d := NewDiscoveryClient(targetAddr, scope, []exporter.MetricExporter{exporter1})
+
+	res, err := d.Discover([]*proto.AccountValue{})
+	if err != nil {
+		t.Errorf("P8sDiscoveryClient.Discover() error = %v", err)
+		return
+	}
+
+	if len(res.EntityDTO) == 0 {
+		t.Errorf("Expected entities along with the warnings")
+	}
+
+	if len(res.ErrorDTO) != 1 || *res.ErrorDTO[0].Severity != proto.ErrorDTO_WARNING {
+		t.Errorf("Expected one error DTO with serverity WARNING but got %v", res.ErrorDTO)
+	}
+}
+
+func TestP8sDiscoveryClient_Discover_Attribute_Mappings(t *testing.T) {
+	metric := newMetric("1.2.3.4", 13.4, 66.7, appType)
+	metric.Attributes = map[string]float64{
+		"request_rate":   20,
+		"error_rate_5xx": 0.25,
+	}
+	exporter1 := &mockExporter{
+		metrics: []*exporter.EntityMetric{metric},
+	}
+
+	d := NewDiscoveryClient(targetAddr, scope, []exporter.MetricExporter{exporter1})
+	d.SetAttributeMappings([]*dtofactory.AttributeMapping{
+		{Attribute: "error_rate_5xx", Commodity: "SLA_COMMODITY", Capacity: 1},
+		{Attribute: "request_rate", Property: "requestRate"},
+		{Attribute: "error_rate_4xx", Property: "errorRate4xx"},
+	})
+
+	res, err := d.Discover([]*proto.AccountValue{})
+	if err != nil || len(res.EntityDTO) != 2 {
+		t.Errorf("P8sDiscoveryClient.Discover() = %v, %v", res, err)
+		return
+	}
+
+	app, vapp := res.EntityDTO[0], res.EntityDTO[1]
+	found := false
+	for _, comm := range app.CommoditiesSold {
+		if comm.GetCommodityType() == proto.CommodityDTO_SLA_COMMODITY {
+			found = comm.GetUsed() == 0.25 && comm.GetCapacity() == 1 && comm.GetKey() == "1.2.3.4"
+		}
+	}
+	if !found {
+		t.Errorf("Expected the sold SLA commodity mapped from error_rate_5xx, got %v", app.CommoditiesSold)
+	}
+
+	properties := make(map[string]string)
+	for _, p := range app.EntityProperties {
+		properties[p.GetName()] = p.GetValue()
+	}
+	if properties["requestRate"] != "20" || len(properties) != 2 {
+		t.Errorf("Wrong entity properties: %v", properties)
+	}
+
+	if len(vapp.CommoditiesBought) != 1 || len(vapp.CommoditiesBought[0].Bought) != len(app.CommoditiesSold) {
+		t.Errorf("The vApp should buy all the commodities of the app: %v", vapp.CommoditiesBought)
+	}
+}
+
+func TestP8sDiscoveryClient_Discover_Keyed_Metrics(t *testing.T) {
+	metric := newMetric("1.2.3.4", 15, 20, appType)
+	metric.KeyedMetrics = map[string]map[proto.CommodityDTO_CommodityType]float64{
+		"cart.Cart/AddItem": {proto.CommodityDTO_TRANSACTION: 12, proto.CommodityDTO_RESPONSE_TIME: 18},
+		"cart.Cart/GetCart": {proto.CommodityDTO_TRANSACTION: 3, proto.CommodityDTO_RESPONSE_TIME: 28},
+	}
+	exporter1 := &mockExporter{
+		metrics: []*exporter.EntityMetric{metric},
+	}
+
+	d := NewDiscoveryClient(targetAddr, scope, []exporter.MetricExporter{exporter1})
+	res, err := d.Discover([]*proto.AccountValue{})
+	if err != nil || len(res.EntityDTO) != 2 {
+		t.Errorf("P8sDiscoveryClient.Discover() = %v, %v", res, err)
+		return
+	}
+
+	app, vapp := res.EntityDTO[0], res.EntityDTO[1]
+	used := make(map[string]float64)
+	for _, comm := range app.CommoditiesSold {
+		used[comm.GetCommodityType().String()+"|"+comm.GetKey()] = comm.GetUsed()
+	}
+
+	expected := map[string]float64{
+		"TRANSACTION|1.2.3.4":                     15,
+		"RESPONSE_TIME|1.2.3.4":                   20,
+		"TRANSACTION|1.2.3.4/cart.Cart/AddItem":   12,
+		"RESPONSE_TIME|1.2.3.4/cart.Cart/AddItem": 18,
+		"TRANSACTION|1.2.3.4/cart.Cart/GetCart":   3,
+		"RESPONSE_TIME|1.2.3.4/cart.Cart/GetCart": 28,
+	}
+	if !reflect.DeepEqual(used, expected) {
+		t.Errorf("Wrong sold commodities: %v", used)
+	}
+
+	if len(vapp.CommoditiesBought) != 1 || len(vapp.CommoditiesBought[0].Bought) != len(app.CommoditiesSold) {
+		t.Errorf("The vApp should buy all the commodities of the app: %v", vapp.CommoditiesBought)
+	}
+}
+
+func TestP8sDiscoveryClient_Discover_BusinessApp(t *testing.T) {
+	metric := &exporter.EntityMetric{
+		UID:  "https://shop.example.com",
+		Type: proto.EntityDTO_BUSINESS_APPLICATION,
+		KeyedMetrics: map[string]map[proto.CommodityDTO_CommodityType]float64{
+			"probe": {proto.CommodityDTO_RESPONSE_TIME: 120.5},
+		},
+		Attributes: map[string]float64{"availability": 0.75},
+	}
+	exporter1 := &mockExporter{
+		metrics: []*exporter.EntityMetric{metric},
+	}
+
+	d := NewDiscoveryClient(targetAddr, scope, []exporter.MetricExporter{exporter1})
+	d.SetAttributeMappings([]*dtofactory.AttributeMapping{
+		{Attribute: "availability", Commodity: "SLA_COMMODITY", Capacity: 1},
+	})
+
+	res, err := d.Discover([]*proto.AccountValue{})
+	if err != nil || len(res.EntityDTO) != 1 {
+		t.Errorf("P8sDiscoveryClient.Discover() = %v, %v", res, err)
+		return
+	}
+
+	entity := res.EntityDTO[0]
+	// a discovered entity, not a proxy to be stitched
+	if entity.GetEntityType() != proto.EntityDTO_BUSINESS_APPLICATION || len(entity.CommoditiesBought) != 0 ||
+		entity.GetReplacementEntityData() != nil || entity.Monitored != nil {
+		t.Errorf("Wrong business application: %v", entity)
+	}
+
+	used := make(map[string]float64)
+	for _, comm := range entity.CommoditiesSold {
+		used[comm.GetCommodityType().String()+":"+comm.GetKey()] = comm.GetUsed()
+	}
+
+	expected := map[string]float64{
+		"RESPONSE_TIME:" + metric.UID + "/probe": 120.5,
+		"SLA_COMMODITY:" + metric.UID:            0.75,
+	}
+	if !reflect.DeepEqual(used, expected) {
+		t.Errorf("Wrong sold commodities: %v", used)
+	}
+}
+
+func TestP8sDiscoveryClient_Discover_Edges(t *testing.T) {
+	vappType := proto.EntityDTO_VIRTUAL_APPLICATION
+	exporter1 := &mockExporter{
+		metrics: []*exporter.EntityMetric{
+			newMetric("default/productpage", 10, 100, vappType),
+			newMetric("default/reviews", 8, 40, vappType),
+		},
+		edges: []*exporter.Edge{
+			{
+				Caller: "default/productpage", CallerType: vappType,
+				Callee: "default/reviews", CalleeType: vappType,
+				Metrics: map[proto.CommodityDTO_CommodityType]float64{
+					proto.CommodityDTO_TRANSACTION:   6,
+					proto.CommodityDTO_RESPONSE_TIME: 35,
+				},
+			},
+			{
+				Caller: "default/productpage", CallerType: vappType,
+				Callee: "default/details", CalleeType: vappType,
+			},
+		},
+	}
+
+	d := NewDiscoveryClient(targetAddr, scope, []exporter.MetricExporter{exporter1})
+	res, err := d.Discover([]*proto.AccountValue{})
+	if err != nil || len(res.EntityDTO) != 2 {
+		t.Errorf("P8sDiscoveryClient.Discover() = %v, %v", res, err)
+		return
+	}
+
+	callerId := "VIRTUAL_APPLICATION-" + scope + "/default/productpage"
+	calleeId := "VIRTUAL_APPLICATION-" + scope + "/default/reviews"
+	for _, e := range res.EntityDTO {
+		if e.GetId() != callerId {
+			if len(e.CommoditiesBought) != 0 {
+				t.Errorf("The callee should not buy commodities: %v", e.CommoditiesBought)
+			}
+			continue
+		}
+
+		if len(e.CommoditiesBought) != 1 || e.CommoditiesBought[0].GetProviderId() != calleeId {
+			t.Errorf("The caller should buy from the callee only: %v", e.CommoditiesBought)
+			continue
+		}
+
+		for _, comm := range e.CommoditiesBought[0].Bought {
+			if comm.GetKey() != "default/reviews" {
+				t.Errorf("Wrong key of the bought commodity: %v", comm)
+			}
+			if comm.GetCommodityType() == proto.CommodityDTO_TRANSACTION && comm.GetUsed() != 6 {
+				t.Errorf("Wrong TPS of the edge: %v", comm)
+			}
+		}
+	}
+}
+
+func TestP8sDiscoveryClient_Discover_DB_Commodities(t *testing.T) {
+	metric := newMetric("10.2.3.31", 300, 0.25, appType)
+	metric.Metrics[proto.CommodityDTO_DB_MEM] = 2048
+	metric.Metrics[proto.CommodityDTO_CONNECTION] = 12
+	metric.Metrics[proto.CommodityDTO_DB_CACHE_HIT_RATE] = 92.5
+	metric.Capacities = map[proto.CommodityDTO_CommodityType]float64{
+		proto.CommodityDTO_DB_MEM: 1048576,
+	}
+	exporter1 := &mockExporter{
+		metrics: []*exporter.EntityMetric{metric},
+	}
+
+	d := NewDiscoveryClient(targetAddr, scope, []exporter.MetricExporter{exporter1})
+	res, err := d.Discover([]*proto.AccountValue{})
+	if err != nil || len(res.EntityDTO) != 2 {
+		t.Errorf("P8sDiscoveryClient.Discover() = %v, %v", res, err)
+		return
+	}
+
+	capacities := make(map[proto.CommodityDTO_CommodityType]float64)
+	for _, comm := range res.EntityDTO[0].CommoditiesSold {
+		capacities[comm.GetCommodityType()] = comm.GetCapacity()
+	}
+
+	expected := map[proto.CommodityDTO_CommodityType]float64{
+		proto.CommodityDTO_TRANSACTION:       300,
+		proto.CommodityDTO_RESPONSE_TIME:     constant.LatencyCap,
+		proto.CommodityDTO_DB_MEM:            1048576,
+		proto.CommodityDTO_CONNECTION:        constant.ConnectionCap,
+		proto.CommodityDTO_DB_CACHE_HIT_RATE: constant.DBCacheHitRateCap,
+	}
+	if !reflect.DeepEqual(capacities, expected) {
+		t.Errorf("Wrong capacities of the sold commodities: %v", capacities)
+	}
+}
+
+func TestP8sDiscoveryClient_Discover_JVM_Commodities(t *testing.T) {
+	metric := newMetric("10.2.3.7", 10, 85, appType)
+	metric.Metrics[proto.CommodityDTO_HEAP] = 262144
+	metric.Metrics[proto.CommodityDTO_COLLECTION_TIME] = 2.5
+	metric.Metrics[proto.CommodityDTO_THREADS] = 48
+	metric.Capacities = map[proto.CommodityDTO_CommodityType]float64{
+		proto.CommodityDTO_HEAP: 1048576,
+	}
+	exporter1 := &mockExporter{
+		metrics: []*exporter.EntityMetric{metric},
+	}
+
+	d := NewDiscoveryClient(targetAddr, scope, []exporter.MetricExporter{exporter1})
+	res, err := d.Discover([]*proto.AccountValue{})
+	if err != nil || len(res.EntityDTO) != 2 {
+		t.Errorf("P8sDiscoveryClient.Discover() = %v, %v", res, err)
+		return
+	}
+
+	capacities := make(map[proto.CommodityDTO_CommodityType]float64)
+	for _, comm := range res.EntityDTO[0].CommoditiesSold {
+		capacities[comm.GetCommodityType()] = comm.GetCapacity()
+	}
+
+	expected := map[proto.CommodityDTO_CommodityType]float64{
+		proto.CommodityDTO_TRANSACTION:     constant.TPSCap,
+		proto.CommodityDTO_RESPONSE_TIME:   constant.LatencyCap,
+		proto.CommodityDTO_HEAP:            1048576,
+		proto.CommodityDTO_COLLECTION_TIME: constant.CollectionTimeCap,
+		proto.CommodityDTO_THREADS:         constant.ThreadsCap,
+	}
+	if !reflect.DeepEqual(capacities, expected) {
+		t.Errorf("Wrong capacities of the sold commodities: %v", capacities)
+	}
+}
+
+func TestP8sDiscoveryClient_Discover_VM(t *testing.T) {
+	metric := &exporter.EntityMetric{
+		UID:    "vm/10.10.0.5",
+		Type:   proto.EntityDTO_VIRTUAL_MACHINE,
+		Labels: map[string]string{"ip": "10.10.0.5"},
+		Metrics: map[proto.CommodityDTO_CommodityType]float64{
+			proto.CommodityDTO_VCPU:           3000,
+			proto.CommodityDTO_VMEM:           4194304,
+			proto.CommodityDTO_NET_THROUGHPUT: 1250,
+		},
+		Capacities: map[proto.CommodityDTO_CommodityType]float64{
+			proto.CommodityDTO_VCPU: 8000,
+			proto.CommodityDTO_VMEM: 16777216,
+		},
+	}
+	exporter1 := &mockExporter{
+		metrics: []*exporter.EntityMetric{metric},
+	}
+
+	d := NewDiscoveryClient(targetAddr, scope, []exporter.MetricExporter{exporter1})
+	res, err := d.Discover([]*proto.AccountValue{})
+	if err != nil || len(res.EntityDTO) != 1 {
+		t.Errorf("P8sDiscoveryClient.Discover() = %v, %v", res, err)
+		return
+	}
+
+	vm := res.EntityDTO[0]
+	if vm.GetEntityType() != proto.EntityDTO_VIRTUAL_MACHINE || len(vm.CommoditiesSold) != 3 {
+		t.Errorf("Wrong VM entity: %v", vm)
+	}
+
+	capacities := make(map[proto.CommodityDTO_CommodityType]float64)
+	for _, comm := range vm.CommoditiesSold {
+		if comm.Key != nil {
+			t.Errorf("The commodity of VM should have no key: %v", comm)
+		}
+		capacities[comm.GetCommodityType()] = comm.GetCapacity()
+	}
+
+	expected := map[proto.CommodityDTO_CommodityType]float64{
+		proto.CommodityDTO_VCPU:           8000,
+		proto.CommodityDTO_VMEM:           16777216,
+		proto.CommodityDTO_NET_THROUGHPUT: constant.NetThroughputCap,
+	}
+	if !reflect.DeepEqual(capacities, expected) {
+		t.Errorf("Wrong capacities of the sold commodities: %v", capacities)
+	}
+
+	// stitched with the VM of the same IP, patching the used values only
+	if vm.GetReplacementEntityData() == nil || len(vm.EntityProperties) != 1 || vm.EntityProperties[0].GetValue() != "10.10.0.5" {
+		t.Errorf("Wrong stitching data of VM: %v", vm)
+	}
+}
+
+func TestP8sDiscoveryClient_Discover_Containers(t *testing.T) {
+	newResourceMetric := func(uid string, entityType proto.EntityDTO_EntityType, vcpu, vmem float64) *exporter.EntityMetric {
+		return &exporter.EntityMetric{
+			UID:    uid,
+			Type:   entityType,
+			Labels: map[string]string{"ip": "10.2.4.15"},
+			Metrics: map[proto.CommodityDTO_CommodityType]float64{
+				proto.CommodityDTO_VCPU: vcpu,
+				proto.CommodityDTO_VMEM: vmem,
+			},
+			Capacities: map[proto.CommodityDTO_CommodityType]float64{
+				proto.CommodityDTO_VCPU: 4000,
+				proto.CommodityDTO_VMEM: 16777216,
+			},
+		}
+	}
+	exporter1 := &mockExporter{
+		metrics: []*exporter.EntityMetric{
+			newResourceMetric("shop/cart-0/app", proto.EntityDTO_CONTAINER, 250, 262144),
+			newResourceMetric("shop/cart-0", proto.EntityDTO_CONTAINER_POD, 300, 327680),
+		},
+	}
+
+	// the pod without the IP can't be stitched
+	noIPPod := newResourceMetric("shop/cart-1", proto.EntityDTO_CONTAINER_POD, 100, 65536)
+	noIPPod.Labels = map[string]string{}
+	exporter1.metrics = append(exporter1.metrics, noIPPod)
+
+	// only the pod with the IP is stitched with the one discovered by kubeturbo by default
+	d := NewDiscoveryClient(targetAddr, scope, []exporter.MetricExporter{exporter1})
+	res, err := d.Discover([]*proto.AccountValue{})
+	if err != nil || len(res.EntityDTO) != 1 {
+		t.Errorf("P8sDiscoveryClient.Discover() = %v, %v", res, err)
+		return
+	}
+	pod := res.EntityDTO[0]
+	if pod.GetEntityType() != proto.EntityDTO_CONTAINER_POD || pod.GetReplacementEntityData() == nil || pod.GetMonitored() {
+		t.Errorf("The pod %v should be a proxy: %v", pod.GetId(), pod)
+	}
+	if props := pod.GetEntityProperties(); len(props) != 1 || props[0].GetName() != ipAttr || props[0].GetValue() != "10.2.4.15" {
+		t.Errorf("Wrong stitching property of the pod %v: %v", pod.GetId(), props)
+	}
+
+	// reported as discovered entities for the clusters without kubeturbo if enabled
+	d.SetContainerDiscovery(true)
+	res, err = d.Discover([]*proto.AccountValue{})
+	if err != nil || len(res.EntityDTO) != 3 {
+		t.Errorf("P8sDiscoveryClient.Discover() = %v, %v", res, err)
+		return
+	}
+	for _, entity := range res.EntityDTO {
+		if len(entity.CommoditiesSold) != 2 {
+			t.Errorf("Wrong commodities of %v: %v", entity.GetId(), entity.CommoditiesSold)
+		}
+		for _, comm := range entity.CommoditiesSold {
+			if comm.Key != nil {
+				t.Errorf("The commodity of %v should have no key: %v", entity.GetId(), comm)
+			}
+		}
+		if entity.GetReplacementEntityData() != nil || entity.Monitored != nil {
+			t.Errorf("The entity %v should not be a proxy: %v", entity.GetId(), entity)
+		}
+	}
+}
+
 type mockExporter struct {
-	metrics []*exporter.EntityMetric
-	err     error
+	metrics  []*exporter.EntityMetric
+	edges    []*exporter.Edge
+	warnings []*proto.ErrorDTO
+	err      error
 }
 
-func (m *mockExporter) Query() ([]*exporter.EntityMetric, error) {
-	return m.metrics, m.err
+func (m *mockExporter) Query() (*exporter.QueryResult, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &exporter.QueryResult{
+		Metrics:  m.metrics,
+		Edges:    m.edges,
+		Warnings: m.warnings,
+	}, nil
 }
 
 func (m *mockExporter) Validate() bool {
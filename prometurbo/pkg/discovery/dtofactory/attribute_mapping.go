@@ -0,0 +1,70 @@
+package dtofactory
+
+import (
+	"fmt"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// AttributeMapping maps an attribute of the entity metrics (e.g., "error_rate_5xx") to
+// either a sold commodity of the entity, or an entity property.
+type AttributeMapping struct {
+	Attribute string `json:"attribute"`
+
+	// the commodity type, e.g., "APPLICATION"; its capacity is required
+	Commodity string  `json:"commodity,omitempty"`
+	Capacity  float64 `json:"capacity,omitempty"`
+
+	// the name of the entity property in the DEFAULT namespace
+	Property string `json:"property,omitempty"`
+}
+
+func (m *AttributeMapping) Validate() error {
+	if len(m.Attribute) < 1 {
+		return fmt.Errorf("Attribute of the mapping is empty")
+	}
+
+	if (len(m.Commodity) < 1) == (len(m.Property) < 1) {
+		return fmt.Errorf("Attribute %v: either commodity or property should be set", m.Attribute)
+	}
+
+	if len(m.Commodity) > 0 {
+		if _, err := m.GetCommodityType(); err != nil {
+			return fmt.Errorf("Attribute %v: %v", m.Attribute, err)
+		}
+		if m.Capacity <= 0 {
+			return fmt.Errorf("Attribute %v: invalid capacity %v", m.Attribute, m.Capacity)
+		}
+	}
+
+	return nil
+}
+
+func (m *AttributeMapping) IsCommodity() bool {
+	return len(m.Commodity) > 0
+}
+
+func (m *AttributeMapping) GetCommodityType() (proto.CommodityDTO_CommodityType, error) {
+	v, ok := proto.CommodityDTO_CommodityType_value[m.Commodity]
+	if !ok {
+		return proto.CommodityDTO_UNKNOWN, fmt.Errorf("Unknown commodity type: %v", m.Commodity)
+	}
+	return proto.CommodityDTO_CommodityType(v), nil
+}
+
+// MappedCommodityTypes returns the commodity types of the mappings, which should be added to the supply chain
+func MappedCommodityTypes(mappings []*AttributeMapping) []proto.CommodityDTO_CommodityType {
+	result := []proto.CommodityDTO_CommodityType{}
+	seen := make(map[proto.CommodityDTO_CommodityType]bool)
+	for _, m := range mappings {
+		if !m.IsCommodity() {
+			continue
+		}
+		ctype, err := m.GetCommodityType()
+		if err != nil || seen[ctype] {
+			continue
+		}
+		seen[ctype] = true
+		result = append(result, ctype)
+	}
+	return result
+}
@@ -0,0 +1,84 @@
+package dtofactory
+
+import (
+	"github.com/golang/glog"
+	"github.com/turbonomic/prometurbo/prometurbo/pkg/discovery/exporter"
+	"github.com/turbonomic/turbo-go-sdk/pkg/builder"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+type edgeBuilder struct {
+	scope string
+	edges []*exporter.Edge
+}
+
+func NewEdgeBuilder(scope string, edges []*exporter.Edge) *edgeBuilder {
+	return &edgeBuilder{
+		scope: scope,
+		edges: edges,
+	}
+}
+
+// Build adds the edges to the entities as buyer/seller relationships: the caller buys the commodities
+// of the edge from the callee. The edges whose caller or callee is not in the entities are skipped.
+func (b *edgeBuilder) Build(entities []*proto.EntityDTO) {
+	entityMap := make(map[string]*proto.EntityDTO)
+	for _, e := range entities {
+		entityMap[e.GetId()] = e
+	}
+
+	for _, edge := range b.edges {
+		callerId := getEntityId(b.scope, edge.CallerType, edge.Caller)
+		calleeId := getEntityId(b.scope, edge.CalleeType, edge.Callee)
+		caller, ok := entityMap[callerId]
+		if !ok {
+			glog.V(3).Infof("Skip edge %v->%v: caller is not found", callerId, calleeId)
+			continue
+		}
+		callee, ok := entityMap[calleeId]
+		if !ok {
+			glog.V(3).Infof("Skip edge %v->%v: callee is not found", callerId, calleeId)
+			continue
+		}
+		if callerId == calleeId {
+			continue
+		}
+
+		commodities := b.createBoughtCommodities(edge, callee)
+		if len(commodities) < 1 {
+			continue
+		}
+
+		calleeType := callee.GetEntityType()
+		caller.CommoditiesBought = append(caller.CommoditiesBought, &proto.EntityDTO_CommodityBought{
+			ProviderId:   &calleeId,
+			ProviderType: &calleeType,
+			Bought:       commodities,
+		})
+	}
+}
+
+// Creates the commodities of the edge sold by the callee, with the same keys
+func (b *edgeBuilder) createBoughtCommodities(edge *exporter.Edge, callee *proto.EntityDTO) []*proto.CommodityDTO {
+	commodities := []*proto.CommodityDTO{}
+
+	for _, sold := range callee.CommoditiesSold {
+		commType := sold.GetCommodityType()
+		value, ok := edge.Metrics[commType]
+		if !ok {
+			continue
+		}
+
+		commodity, err := builder.NewCommodityDTOBuilder(commType).
+			Used(value).Key(sold.GetKey()).Create()
+
+		if err != nil {
+			glog.Errorf("Error building a commodity of edge %v->%v: %s", edge.Caller, edge.Callee, err)
+			continue
+		}
+
+		commodities = append(commodities, commodity)
+	}
+
+	return commodities
+}
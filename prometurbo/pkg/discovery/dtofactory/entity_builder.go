@@ -7,6 +7,8 @@ import (
 	"github.com/turbonomic/prometurbo/prometurbo/pkg/discovery/exporter"
 	"github.com/turbonomic/turbo-go-sdk/pkg/builder"
 	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+	"sort"
+	"strconv"
 )
 
 type entityBuilder struct {
@@ -14,6 +16,12 @@ type entityBuilder struct {
 	scope string
 
 	metric *exporter.EntityMetric
+
+	// the attributes of the metric mapped to commodities or properties
+	attributeMappings []*AttributeMapping
+
+	// whether the containers and pods are reported as discovered entities, instead of stitched with the ones of kubeturbo
+	discoverContainers bool
 }
 
 func NewEntityBuilder(scope string, metric *exporter.EntityMetric) *entityBuilder {
@@ -23,9 +31,24 @@ func NewEntityBuilder(scope string, metric *exporter.EntityMetric) *entityBuilde
 	}
 }
 
+func (b *entityBuilder) WithAttributeMappings(mappings []*AttributeMapping) *entityBuilder {
+	b.attributeMappings = mappings
+	return b
+}
+
+func (b *entityBuilder) WithContainerDiscovery(discover bool) *entityBuilder {
+	b.discoverContainers = discover
+	return b
+}
+
 func (b *entityBuilder) Build() ([]*proto.EntityDTO, error) {
 	metric := b.metric
-	ip := metric.UID
+	ip := b.getIP()
+
+	if !b.canStitch() {
+		glog.V(3).Infof("Skip %v %v: no property to stitch with the one discovered by kubeturbo", metric.Type, metric.UID)
+		return []*proto.EntityDTO{}, nil
+	}
 
 	entityDto, err := b.createEntityDto()
 
@@ -36,7 +59,12 @@ func (b *entityBuilder) Build() ([]*proto.EntityDTO, error) {
 
 	dtos := []*proto.EntityDTO{entityDto}
 
-	consumerDto, err := b.createConsumerEntity(entityDto, ip)
+	// Only the applications need the proxy consumers; other entities, e.g., the services (vApps), are reported as is
+	if metric.Type != proto.EntityDTO_APPLICATION {
+		return dtos, nil
+	}
+
+	consumerDto, err := b.createConsumerEntity(entityDto, metric.UID, ip)
 
 	if err != nil {
 		glog.Errorf("Error building consumer EntityDTO from metric %v: %s", metric, err)
@@ -47,10 +75,67 @@ func (b *entityBuilder) Build() ([]*proto.EntityDTO, error) {
 	return dtos, nil
 }
 
+// getIP returns the IP of the entity for stitching: the "ip" label if exists, otherwise the UID
+func (b *entityBuilder) getIP() string {
+	if ip, ok := b.metric.Labels[constant.IPLabel]; ok && len(ip) > 0 {
+		return ip
+	}
+	return b.metric.UID
+}
+
+// isProxy returns whether the entity is a proxy, stitched with the one discovered by other probes;
+// the business applications, not discovered by other probes, are discovered entities,
+// and so are the containers and pods if they are reported for the clusters without kubeturbo
+func (b *entityBuilder) isProxy() bool {
+	if b.metric.Type == proto.EntityDTO_BUSINESS_APPLICATION {
+		return false
+	}
+	if _, ok := constant.ContainerEntityTypeMap[b.metric.Type]; ok {
+		return !b.discoverContainers
+	}
+	return true
+}
+
+// canStitch returns whether the proxy of a container or pod can be stitched with the one discovered by kubeturbo,
+// which is matched by the pod IP: the pods with the "ip" label can, while the containers, sharing the IP of their pods,
+// can't be told apart, so their usages are only stitched as the ones of their pods
+func (b *entityBuilder) canStitch() bool {
+	if _, ok := constant.ContainerEntityTypeMap[b.metric.Type]; !ok || !b.isProxy() {
+		return true
+	}
+	if b.metric.Type == proto.EntityDTO_CONTAINER {
+		return false
+	}
+	ip, ok := b.metric.Labels[constant.IPLabel]
+	return ok && len(ip) > 0
+}
+
+// getCommodityKey returns the key of the sold commodities: the IP for the applications,
+// and no key for the VMs and containers, to match the commodities discovered by other probes
+func (b *entityBuilder) getCommodityKey(ip string) string {
+	if _, ok := constant.ResourceEntityTypeMap[b.metric.Type]; ok {
+		return ""
+	}
+	return ip
+}
+
+// newCommodity builds a sold commodity, with the key if not empty
+func newCommodity(commType proto.CommodityDTO_CommodityType, used, capacity float64, key string) (*proto.CommodityDTO, error) {
+	b := builder.NewCommodityDTOBuilder(commType).Used(used).Capacity(capacity)
+	if len(key) > 0 {
+		b.Key(key)
+	}
+	return b.Create()
+}
+
 func (b *entityBuilder) getEntityId(entityType proto.EntityDTO_EntityType, entityName string) string {
+	return getEntityId(b.scope, entityType, entityName)
+}
+
+func getEntityId(scope string, entityType proto.EntityDTO_EntityType, entityName string) string {
 	eType := proto.EntityDTO_EntityType_name[int32(entityType)]
 
-	return fmt.Sprintf("%s-%s/%s", eType, b.scope, entityName)
+	return fmt.Sprintf("%s-%s/%s", eType, scope, entityName)
 }
 
 func getReplacementMetaData(entityType proto.EntityDTO_EntityType, commTypes []proto.CommodityDTO_CommodityType, bought bool) *proto.EntityDTO_ReplacementEntityMetaData {
@@ -65,11 +150,17 @@ func getReplacementMetaData(entityType proto.EntityDTO_EntityType, commTypes []p
 			UseTopoExt: &useTopoExt,
 		})
 
+	// The capacities of the VMs and containers are kept as discovered by other probes
+	soldProperties := []string{constant.Used, constant.Capacity}
+	if _, ok := constant.ResourceEntityTypeMap[entityType]; ok {
+		soldProperties = []string{constant.Used}
+	}
+
 	for _, commType := range commTypes {
 		if bought {
 			b.PatchBuyingWithProperty(commType, []string{constant.Used})
 		} else {
-			b.PatchSellingWithProperty(commType, []string{constant.Used, constant.Capacity})
+			b.PatchSellingWithProperty(commType, soldProperties)
 		}
 	}
 
@@ -88,9 +179,9 @@ func getEntityProperty(value string) *proto.EntityDTO_EntityProperty {
 }
 
 // Creates consumer entity from a given provider entity. Currently, the use case is to create vApp from Application.
-func (b *entityBuilder) createConsumerEntity(provider *proto.EntityDTO, ip string) (*proto.EntityDTO, error) {
+func (b *entityBuilder) createConsumerEntity(provider *proto.EntityDTO, uid, ip string) (*proto.EntityDTO, error) {
 	entityType := *provider.EntityType
-	id := b.getEntityId(entityType, ip)
+	id := b.getEntityId(entityType, uid)
 	commodities := provider.CommoditiesSold
 
 	commTypes := []proto.CommodityDTO_CommodityType{}
@@ -133,15 +224,20 @@ func (b *entityBuilder) createEntityDto() (*proto.EntityDTO, error) {
 		return nil, err
 	}
 
-	ip := metric.UID
+	ip := b.getIP()
+	commKey := b.getCommodityKey(ip)
 
 	commodities := []*proto.CommodityDTO{}
 	commTypes := []proto.CommodityDTO_CommodityType{}
+	// The entities with only attributes or keyed metrics, e.g., the targets probed by blackbox_exporter, have no metrics
+	if metric.Metrics == nil {
+		metric.Metrics = make(map[proto.CommodityDTO_CommodityType]float64)
+	}
 	commMetrics := metric.Metrics
 
 	// If metric exporter doesn't provide the necessary commodity usage, create one with value 0.
 	// TODO: This is to match the supply chain and should be removed.
-	for commType := range constant.CommodityTypeMap {
+	for _, commType := range constant.DefaultCommodityTypeMap[entityType] {
 		if _, ok := commMetrics[commType]; !ok {
 			commMetrics[commType] = 0
 		}
@@ -156,7 +252,11 @@ func (b *entityBuilder) createEntityDto() (*proto.EntityDTO, error) {
 			continue
 		}
 
-		capacity, ok := constant.CommodityCapMap[commType]
+		// The capacity reported by the exporter overrides the default one
+		capacity, ok := metric.Capacities[commType]
+		if !ok || capacity <= 0 {
+			capacity, ok = constant.CommodityCapMap[commType]
+		}
 		if !ok {
 			err := fmt.Errorf("Missing commodity capacity for type %s", commType)
 			glog.Errorf(err.Error())
@@ -168,8 +268,7 @@ func (b *entityBuilder) createEntityDto() (*proto.EntityDTO, error) {
 			capacity = value
 		}
 
-		commodity, err := builder.NewCommodityDTOBuilder(commType).
-			Used(value).Capacity(capacity).Key(ip).Create()
+		commodity, err := newCommodity(commType, value, capacity, commKey)
 
 		if err != nil {
 			glog.Errorf("Error building a commodity: %s", err)
@@ -180,15 +279,36 @@ func (b *entityBuilder) createEntityDto() (*proto.EntityDTO, error) {
 		commTypes = append(commTypes, commType)
 	}
 
-	id := b.getEntityId(entityType, ip)
+	attrCommodities, attrCommTypes := b.createAttributeCommodities(commKey)
+	commodities = append(commodities, attrCommodities...)
+	commTypes = append(commTypes, attrCommTypes...)
 
-	entityDto, err := builder.NewEntityDTOBuilder(entityType, id).
+	keyedCommodities, keyedCommTypes := b.createKeyedCommodities(commKey)
+	commodities = append(commodities, keyedCommodities...)
+	for _, commType := range keyedCommTypes {
+		if !hasCommodityType(commTypes, commType) {
+			commTypes = append(commTypes, commType)
+		}
+	}
+
+	id := b.getEntityId(entityType, metric.UID)
+
+	entityDtoBuilder := builder.NewEntityDTOBuilder(entityType, id).
 		DisplayName(id).
 		SellsCommodities(commodities).
-		WithProperty(getEntityProperty(ip)).
-		ReplacedBy(getReplacementMetaData(entityType, commTypes, false)).
-		Monitored(false).
-		Create()
+		WithProperty(getEntityProperty(ip))
+
+	for _, property := range b.createAttributeProperties() {
+		entityDtoBuilder.WithProperty(property)
+	}
+
+	if b.isProxy() {
+		entityDtoBuilder.
+			ReplacedBy(getReplacementMetaData(entityType, commTypes, false)).
+			Monitored(false)
+	}
+
+	entityDto, err := entityDtoBuilder.Create()
 
 	if err != nil {
 		glog.Errorf("Error building EntityDTO from metric %v: %s", metric, err)
@@ -197,3 +317,131 @@ func (b *entityBuilder) createEntityDto() (*proto.EntityDTO, error) {
 
 	return entityDto, nil
 }
+
+// Creates the sold commodities from the attributes mapped to commodities
+func (b *entityBuilder) createAttributeCommodities(key string) ([]*proto.CommodityDTO, []proto.CommodityDTO_CommodityType) {
+	commodities := []*proto.CommodityDTO{}
+	commTypes := []proto.CommodityDTO_CommodityType{}
+
+	for _, m := range b.attributeMappings {
+		if !m.IsCommodity() {
+			continue
+		}
+
+		value, ok := b.metric.Attributes[m.Attribute]
+		if !ok {
+			continue
+		}
+
+		commType, err := m.GetCommodityType()
+		if err != nil {
+			glog.Errorf("%v", err)
+			continue
+		}
+
+		// Adjust the capacity in case utilization > 1 as Market doesn't allow it
+		capacity := m.Capacity
+		if value >= capacity {
+			capacity = value
+		}
+
+		commodity, err := newCommodity(commType, value, capacity, key)
+
+		if err != nil {
+			glog.Errorf("Error building a commodity from attribute %v: %s", m.Attribute, err)
+			continue
+		}
+
+		commodities = append(commodities, commodity)
+		commTypes = append(commTypes, commType)
+	}
+
+	return commodities, commTypes
+}
+
+// Creates the sold commodities of the keyed metrics, e.g., TPS of every gRPC method,
+// with the key "<key of the entity>/<key of the metrics>" and the default capacities
+func (b *entityBuilder) createKeyedCommodities(entityKey string) ([]*proto.CommodityDTO, []proto.CommodityDTO_CommodityType) {
+	commodities := []*proto.CommodityDTO{}
+	commTypes := []proto.CommodityDTO_CommodityType{}
+
+	keys := []string{}
+	for k := range b.metric.KeyedMetrics {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key := k
+		if len(entityKey) > 0 {
+			key = entityKey + "/" + k
+		}
+
+		for commType, value := range b.metric.KeyedMetrics[k] {
+			if _, ok := constant.CommodityTypeMap[commType]; !ok {
+				glog.Errorf("Unsupported commodity type %s of key %v", commType, k)
+				continue
+			}
+
+			capacity, ok := constant.CommodityCapMap[commType]
+			if !ok {
+				glog.Errorf("Missing commodity capacity for type %s of key %v", commType, k)
+				continue
+			}
+
+			// Adjust the capacity in case utilization > 1 as Market doesn't allow it
+			if value >= capacity {
+				capacity = value
+			}
+
+			commodity, err := newCommodity(commType, value, capacity, key)
+			if err != nil {
+				glog.Errorf("Error building a commodity of key %v: %s", k, err)
+				continue
+			}
+
+			commodities = append(commodities, commodity)
+			if !hasCommodityType(commTypes, commType) {
+				commTypes = append(commTypes, commType)
+			}
+		}
+	}
+
+	return commodities, commTypes
+}
+
+func hasCommodityType(commTypes []proto.CommodityDTO_CommodityType, commType proto.CommodityDTO_CommodityType) bool {
+	for _, t := range commTypes {
+		if t == commType {
+			return true
+		}
+	}
+	return false
+}
+
+// Creates the entity properties from the attributes mapped to properties
+func (b *entityBuilder) createAttributeProperties() []*proto.EntityDTO_EntityProperty {
+	properties := []*proto.EntityDTO_EntityProperty{}
+
+	for _, m := range b.attributeMappings {
+		if m.IsCommodity() {
+			continue
+		}
+
+		value, ok := b.metric.Attributes[m.Attribute]
+		if !ok {
+			continue
+		}
+
+		ns := constant.DefaultPropertyNamespace
+		name := m.Property
+		svalue := strconv.FormatFloat(value, 'f', -1, 64)
+		properties = append(properties, &proto.EntityDTO_EntityProperty{
+			Namespace: &ns,
+			Name:      &name,
+			Value:     &svalue,
+		})
+	}
+
+	return properties
+}
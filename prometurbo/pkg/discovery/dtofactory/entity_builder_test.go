@@ -0,0 +1,81 @@
+package dtofactory
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/turbonomic/prometurbo/prometurbo/pkg/discovery/exporter"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+// The entities with only attributes and keyed metrics, e.g., the targets probed by blackbox_exporter,
+// are decoded without the metrics, which are omitted by the exporter if empty
+func TestEntityBuilder_Build_WithoutMetrics(t *testing.T) {
+	body, err := json.Marshal(&exporter.EntityMetric{
+		UID:        "shop/frontend",
+		Type:       proto.EntityDTO_VIRTUAL_APPLICATION,
+		Metrics:    map[proto.CommodityDTO_CommodityType]float64{},
+		Attributes: map[string]float64{"availability": 0.75},
+		KeyedMetrics: map[string]map[proto.CommodityDTO_CommodityType]float64{
+			"probe": {proto.CommodityDTO_RESPONSE_TIME: 120.5},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode the entity metric: %v", err)
+	}
+	metric := &exporter.EntityMetric{}
+	if err := json.Unmarshal(body, metric); err != nil {
+		t.Fatalf("Failed to decode the entity metric: %v", err)
+	}
+	if metric.Type != proto.EntityDTO_VIRTUAL_APPLICATION || metric.Metrics != nil ||
+		metric.KeyedMetrics["probe"][proto.CommodityDTO_RESPONSE_TIME] != 120.5 {
+		t.Fatalf("Wrong entity metric: %+v", metric)
+	}
+
+	dtos, err := NewEntityBuilder("k8s-cluster-foo", metric).Build()
+	if err != nil || len(dtos) != 1 {
+		t.Fatalf("Failed to build the entity: %v, %v", dtos, err)
+	}
+
+	// the default commodities with 0, and the keyed latency of the probes
+	used := make(map[string]float64)
+	for _, comm := range dtos[0].CommoditiesSold {
+		used[comm.GetCommodityType().String()+":"+comm.GetKey()] = comm.GetUsed()
+	}
+	expected := map[string]float64{
+		"TRANSACTION:shop/frontend":         0,
+		"RESPONSE_TIME:shop/frontend":       0,
+		"RESPONSE_TIME:shop/frontend/probe": 120.5,
+	}
+	if len(used) != len(expected) {
+		t.Errorf("Wrong sold commodities: %v", used)
+	}
+	for k, v := range expected {
+		if u, ok := used[k]; !ok || u != v {
+			t.Errorf("Wrong sold commodity %v: %v", k, used)
+		}
+	}
+}
+
+// The pods failing with 5xx only, reported with the error rates but without the metrics of the successful requests
+func TestEntityBuilder_Build_FailingApp(t *testing.T) {
+	metric := &exporter.EntityMetric{
+		UID:        "10.2.1.5",
+		Type:       proto.EntityDTO_APPLICATION,
+		Labels:     map[string]string{"ip": "10.2.1.5", "category": "Istio"},
+		Attributes: map[string]float64{"request_rate": 4, "error_rate_5xx": 1},
+	}
+
+	dtos, err := NewEntityBuilder("k8s-cluster-foo", metric).Build()
+	if err != nil || len(dtos) != 2 {
+		t.Fatalf("Failed to build the entity: %v, %v", dtos, err)
+	}
+
+	used := make(map[proto.CommodityDTO_CommodityType]float64)
+	for _, comm := range dtos[0].CommoditiesSold {
+		used[comm.GetCommodityType()] = comm.GetUsed()
+	}
+	if len(used) != 2 || used[proto.CommodityDTO_TRANSACTION] != 0 || used[proto.CommodityDTO_RESPONSE_TIME] != 0 {
+		t.Errorf("Wrong sold commodities: %v", dtos[0].CommoditiesSold)
+	}
+}
@@ -2,14 +2,18 @@ package exporter
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/golang/glog"
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
 	"io/ioutil"
 	"net/http"
+	"strings"
 	"time"
 )
 
 type MetricExporter interface {
-	Query() ([]*EntityMetric, error)
+	// Query returns the entity metrics and the edges between them, and warnings for the partial failures of the exporter
+	Query() (*QueryResult, error)
 	Validate() bool
 }
 
@@ -37,7 +41,7 @@ func (m *metricExporter) Validate() bool {
 	return true
 }
 
-func (m *metricExporter) Query() ([]*EntityMetric, error) {
+func (m *metricExporter) Query() (*QueryResult, error) {
 	resp, err := sendRequest(m.endpoint)
 	if err != nil {
 		return nil, err
@@ -48,9 +52,13 @@ func (m *metricExporter) Query() ([]*EntityMetric, error) {
 		glog.Errorf("Failed to un-marshal bytes: %v", string(resp))
 		return nil, err
 	}
+
+	result := &QueryResult{
+		Warnings: m.getterWarnings(mr.Getters),
+	}
 	if mr.Status != 0 || len(mr.Data) < 1 {
 		glog.Errorf("Failed to un-marshal MetricResponse: %+v", string(resp))
-		return nil, nil
+		return result, nil
 	}
 
 	glog.V(4).Infof("mr=%+v, len=%d\n", mr, len(mr.Data))
@@ -58,7 +66,46 @@ func (m *metricExporter) Query() ([]*EntityMetric, error) {
 		glog.V(4).Infof("[%d] %+v\n", i, e)
 	}
 
-	return mr.Data, nil
+	result.Metrics = mr.Data
+	result.Edges = mr.Edges
+	return result, nil
+}
+
+// getterWarnings converts the status of the failed getters, and the warnings of the getters
+// (e.g., partial responses of prometheus), to warnings
+func (m *metricExporter) getterWarnings(statuses []*GetterStatus) []*proto.ErrorDTO {
+	warnings := []*proto.ErrorDTO{}
+
+	for _, s := range statuses {
+		name := s.Name
+		if len(s.Source) > 0 {
+			name = fmt.Sprintf("%v@%v", s.Name, s.Source)
+		}
+
+		if !s.Success {
+			description := fmt.Sprintf("Getter %v (%v) of metric exporter %v failed: %v",
+				name, s.Category, m.endpoint, s.Error)
+			warnings = append(warnings, newWarning(description))
+			continue
+		}
+
+		if len(s.Warnings) > 0 {
+			description := fmt.Sprintf("Getter %v (%v) of metric exporter %v got partial results: %v",
+				name, s.Category, m.endpoint, strings.Join(s.Warnings, "; "))
+			warnings = append(warnings, newWarning(description))
+		}
+	}
+
+	return warnings
+}
+
+func newWarning(description string) *proto.ErrorDTO {
+	glog.Warning(description)
+	severity := proto.ErrorDTO_WARNING
+	return &proto.ErrorDTO{
+		Severity:    &severity,
+		Description: &description,
+	}
 }
 
 func sendRequest(endpoint string) ([]byte, error) {
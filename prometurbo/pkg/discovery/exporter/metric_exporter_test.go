@@ -0,0 +1,82 @@
+package exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/turbonomic/turbo-go-sdk/pkg/proto"
+)
+
+func TestMetricExporter_Query_Getter_Warnings(t *testing.T) {
+	body := `{"status":0,"message:omitemtpy":"1 of 2 getters failed",
+		"data:omitempty":[{"uid":"10.2.6.38","type":33,"metrics":{"49":0.3,"52":37.5}}],
+		"getters":[
+			{"name":"istio.app.metric","category":"Istio","success":true,"entityCount":1,"durationMs":35.2},
+			{"name":"redis.app.metric","category":"Redis","success":false,"error":"timeout","entityCount":0,"durationMs":30000}]}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	result, err := NewMetricExporter(server.URL).Query()
+	if err != nil {
+		t.Errorf("Failed to query the exporter: %v", err)
+		return
+	}
+
+	if len(result.Metrics) != 1 {
+		t.Errorf("Wrong number of metrics: %d Vs. 1", len(result.Metrics))
+	}
+
+	if len(result.Warnings) != 1 || *result.Warnings[0].Severity != proto.ErrorDTO_WARNING {
+		t.Errorf("Expected one error DTO with serverity WARNING but got %v", result.Warnings)
+	}
+}
+
+func TestMetricExporter_Query_Partial_Response(t *testing.T) {
+	body := `{"status":0,"message:omitemtpy":"Success",
+		"data:omitempty":[{"uid":"10.2.6.38","type":33,"metrics":{"49":0.3,"52":37.5}}],
+		"getters":[
+			{"name":"istio.app.metric","category":"Istio","success":true,"entityCount":1,"durationMs":35.2,
+			 "warnings":["http://thanos:9090: no StoreAPIs matched for this time range"]}]}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	result, err := NewMetricExporter(server.URL).Query()
+	if err != nil {
+		t.Errorf("Failed to query the exporter: %v", err)
+		return
+	}
+
+	if len(result.Warnings) != 1 || !strings.Contains(*result.Warnings[0].Description, "no StoreAPIs matched") {
+		t.Errorf("Expected one warning of the partial response but got %v", result.Warnings)
+	}
+}
+
+func TestMetricExporter_Query_Edges(t *testing.T) {
+	body := `{"status":0,"message:omitemtpy":"Success",
+		"data:omitempty":[{"uid":"default/productpage","type":26,"metrics":{"49":0.3,"52":37.5}}],
+		"edges":[{"caller":"default/productpage","callerType":26,"callee":"default/reviews","calleeType":26,"metrics":{"49":0.2}}]}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	result, err := NewMetricExporter(server.URL).Query()
+	if err != nil {
+		t.Errorf("Failed to query the exporter: %v", err)
+		return
+	}
+
+	if len(result.Edges) != 1 || result.Edges[0].Callee != "default/reviews" ||
+		result.Edges[0].Metrics[proto.CommodityDTO_TRANSACTION] != 0.2 {
+		t.Errorf("Wrong edges: %+v", result.Edges)
+	}
+}
@@ -3,14 +3,50 @@ package exporter
 import "github.com/turbonomic/turbo-go-sdk/pkg/proto"
 
 type EntityMetric struct {
-	UID     string                                       `json:"uid,omitempty"`
-	Type    proto.EntityDTO_EntityType                   `json:"type,omitempty"`
-	Labels  map[string]string                            `json:"labels,omitempty"`
-	Metrics map[proto.CommodityDTO_CommodityType]float64 `json:"metrics,omitempty"`
+	UID        string                                       `json:"uid,omitempty"`
+	Type       proto.EntityDTO_EntityType                   `json:"type,omitempty"`
+	Labels     map[string]string                            `json:"labels,omitempty"`
+	Metrics    map[proto.CommodityDTO_CommodityType]float64 `json:"metrics,omitempty"`
+	Attributes map[string]float64                           `json:"attributes,omitempty"`
+	Capacities map[proto.CommodityDTO_CommodityType]float64 `json:"capacities,omitempty"`
+
+	// the metrics broken down by a key, e.g., the TPS of every gRPC method
+	KeyedMetrics map[string]map[proto.CommodityDTO_CommodityType]float64 `json:"keyedMetrics,omitempty"`
+}
+
+// Edge is a dependency between two entities: the caller consumes the callee
+type Edge struct {
+	Caller     string                                       `json:"caller"`
+	CallerType proto.EntityDTO_EntityType                   `json:"callerType"`
+	Callee     string                                       `json:"callee"`
+	CalleeType proto.EntityDTO_EntityType                   `json:"calleeType"`
+	Metrics    map[proto.CommodityDTO_CommodityType]float64 `json:"metrics,omitempty"`
+}
+
+// GetterStatus is the result of one entity metric getter of the exporter
+type GetterStatus struct {
+	Name        string   `json:"name"`
+	Category    string   `json:"category,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	Success     bool     `json:"success"`
+	Error       string   `json:"error,omitempty"`
+	EntityCount int      `json:"entityCount"`
+	DurationMs  float64  `json:"durationMs"`
+	Warnings    []string `json:"warnings,omitempty"`
 }
 
 type MetricResponse struct {
 	Status  int             `json:"status"`
 	Message string          `json:"message:omitemtpy"`
 	Data    []*EntityMetric `json:"data:omitempty"`
+	Getters []*GetterStatus `json:"getters,omitempty"`
+	Edges   []*Edge         `json:"edges,omitempty"`
+}
+
+// QueryResult is the result of a query to the exporter
+type QueryResult struct {
+	Metrics []*EntityMetric
+	Edges   []*Edge
+	// warnings for the partial failures of the exporter
+	Warnings []*proto.ErrorDTO
 }
@@ -4,6 +4,7 @@ import (
 	"github.com/golang/glog"
 	"github.com/turbonomic/prometurbo/prometurbo/pkg/conf"
 	"github.com/turbonomic/prometurbo/prometurbo/pkg/discovery"
+	"github.com/turbonomic/prometurbo/prometurbo/pkg/discovery/dtofactory"
 	"github.com/turbonomic/prometurbo/prometurbo/pkg/discovery/exporter"
 	"github.com/turbonomic/prometurbo/prometurbo/pkg/registration"
 	"github.com/turbonomic/turbo-go-sdk/pkg/probe"
@@ -62,9 +63,16 @@ func createTAPService(args *conf.PrometurboArgs) (*service.TAPService, error) {
 	targetAddr := conf.TargetConf.Address
 	scope := conf.TargetConf.Scope
 	metricExporters := []exporter.MetricExporter{exporter.NewMetricExporter(conf.MetricExporterEndpoint)}
+	for _, endpoint := range conf.MetricExporterEndpoints {
+		metricExporters = append(metricExporters, exporter.NewMetricExporter(endpoint))
+	}
 
-	registrationClient := &registration.P8sRegistrationClient{}
+	registrationClient := &registration.P8sRegistrationClient{
+		ExtraCommodities: dtofactory.MappedCommodityTypes(conf.AttributeMappings),
+	}
 	discoveryClient := discovery.NewDiscoveryClient(targetAddr, scope, metricExporters)
+	discoveryClient.SetAttributeMappings(conf.AttributeMappings)
+	discoveryClient.SetContainerDiscovery(conf.DiscoverContainers)
 
 	return service.NewTAPServiceBuilder().
 		WithTurboCommunicator(communicator).
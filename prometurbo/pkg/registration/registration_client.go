@@ -17,12 +17,14 @@ const (
 
 // Implements the TurboRegistrationClient interface
 type P8sRegistrationClient struct {
+	// the commodities sold by the applications in addition to the default ones, e.g., mapped from the attributes
+	ExtraCommodities []proto.CommodityDTO_CommodityType
 }
 
 func (p *P8sRegistrationClient) GetSupplyChainDefinition() []*proto.TemplateDTO {
 	glog.Infoln("Building a supply chain ..........")
 
-	supplyChainFactory := &SupplyChainFactory{}
+	supplyChainFactory := &SupplyChainFactory{extraCommodities: p.ExtraCommodities}
 	templateDtos, err := supplyChainFactory.CreateSupplyChain()
 	if err != nil {
 		glog.Error("Error creating Supply chain for Prometurbo")
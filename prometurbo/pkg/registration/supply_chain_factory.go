@@ -19,9 +19,39 @@ var (
 		CommodityType: &transactionType,
 		Key:           &key,
 	}
+
+	// The commodities sold by the databases, e.g., Redis, in addition to transaction and response time
+	dbCommodityTypes = []proto.CommodityDTO_CommodityType{
+		proto.CommodityDTO_DB_MEM,
+		proto.CommodityDTO_CONNECTION,
+		proto.CommodityDTO_DB_CACHE_HIT_RATE,
+	}
+
+	// The commodities sold by the Java applications
+	jvmCommodityTypes = []proto.CommodityDTO_CommodityType{
+		proto.CommodityDTO_HEAP,
+		proto.CommodityDTO_COLLECTION_TIME,
+		proto.CommodityDTO_THREADS,
+	}
+
+	// The commodities sold by the VMs, without keys as the ones discovered by the hypervisor probes
+	vmCommodityTypes = []proto.CommodityDTO_CommodityType{
+		proto.CommodityDTO_VCPU,
+		proto.CommodityDTO_VMEM,
+		proto.CommodityDTO_NET_THROUGHPUT,
+		proto.CommodityDTO_IO_THROUGHPUT,
+	}
+
+	// The commodities sold by the containers and pods, without keys as the ones discovered by kubeturbo
+	containerCommodityTypes = []proto.CommodityDTO_CommodityType{
+		proto.CommodityDTO_VCPU,
+		proto.CommodityDTO_VMEM,
+	}
 )
 
-type SupplyChainFactory struct{}
+type SupplyChainFactory struct {
+	extraCommodities []proto.CommodityDTO_CommodityType
+}
 
 func (f *SupplyChainFactory) CreateSupplyChain() ([]*proto.TemplateDTO, error) {
 	appNode, err := f.buildAppSupplyBuilder()
@@ -34,7 +64,28 @@ func (f *SupplyChainFactory) CreateSupplyChain() ([]*proto.TemplateDTO, error) {
 		return nil, err
 	}
 
-	return supplychain.NewSupplyChainBuilder().Top(vAppNode).Entity(appNode).
+	vmNode, err := f.buildResourceSupplyBuilder(proto.EntityDTO_VIRTUAL_MACHINE, vmCommodityTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	containerNode, err := f.buildResourceSupplyBuilder(proto.EntityDTO_CONTAINER, containerCommodityTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	podNode, err := f.buildResourceSupplyBuilder(proto.EntityDTO_CONTAINER_POD, containerCommodityTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	bizAppNode, err := f.buildBizAppSupplyBuilder()
+	if err != nil {
+		return nil, err
+	}
+
+	return supplychain.NewSupplyChainBuilder().Top(vAppNode).Entity(bizAppNode).Entity(appNode).
+		Entity(containerNode).Entity(podNode).Entity(vmNode).
 		Create()
 }
 
@@ -42,6 +93,9 @@ func (f *SupplyChainFactory) buildAppSupplyBuilder() (*proto.TemplateDTO, error)
 	builder := supplychain.NewSupplyChainNodeBuilder(proto.EntityDTO_APPLICATION).
 		Sells(transactionTemplateComm).
 		Sells(respTimeTemplateComm)
+	for _, comm := range f.optionalTemplateComms() {
+		builder.Sells(comm)
+	}
 	builder.SetPriority(-1)
 	builder.SetTemplateType(proto.TemplateDTO_BASE)
 	//builder.SetTemplateType(proto.TemplateDTO_EXTENSION)
@@ -51,7 +105,21 @@ func (f *SupplyChainFactory) buildAppSupplyBuilder() (*proto.TemplateDTO, error)
 
 func (f *SupplyChainFactory) buildVAppSupplyBuilder() (*proto.TemplateDTO, error) {
 	builder := supplychain.NewSupplyChainNodeBuilder(proto.EntityDTO_VIRTUAL_APPLICATION).
-		Provider(proto.EntityDTO_APPLICATION, proto.Provider_LAYERED_OVER).
+		Sells(transactionTemplateComm).
+		Sells(respTimeTemplateComm)
+	for _, comm := range f.optionalTemplateComms() {
+		builder.Sells(comm)
+	}
+
+	builder.Provider(proto.EntityDTO_APPLICATION, proto.Provider_LAYERED_OVER).
+		Buys(transactionTemplateComm).
+		Buys(respTimeTemplateComm)
+	for _, comm := range f.optionalTemplateComms() {
+		builder.Buys(comm)
+	}
+
+	// The services calling other services, given by the edges of the exporter
+	builder.Provider(proto.EntityDTO_VIRTUAL_APPLICATION, proto.Provider_LAYERED_OVER).
 		Buys(transactionTemplateComm).
 		Buys(respTimeTemplateComm)
 	builder.SetPriority(-1)
@@ -60,3 +128,58 @@ func (f *SupplyChainFactory) buildVAppSupplyBuilder() (*proto.TemplateDTO, error
 
 	return builder.Create()
 }
+
+// The business applications, e.g., the endpoints probed by blackbox_exporter, selling the user-facing response time,
+// and the extra commodities, e.g., the availability mapped from the attributes
+func (f *SupplyChainFactory) buildBizAppSupplyBuilder() (*proto.TemplateDTO, error) {
+	builder := supplychain.NewSupplyChainNodeBuilder(proto.EntityDTO_BUSINESS_APPLICATION).
+		Sells(respTimeTemplateComm)
+	for _, comm := range f.templateComms(f.extraCommodities) {
+		builder.Sells(comm)
+	}
+	builder.SetPriority(-1)
+	builder.SetTemplateType(proto.TemplateDTO_BASE)
+
+	return builder.Create()
+}
+
+// The VMs (hosts), containers and pods reported by the exporter: the VMs are stitched with the ones
+// discovered by the hypervisor probes, and the pods with the ones of kubeturbo, unless they are reported as discovered entities
+func (f *SupplyChainFactory) buildResourceSupplyBuilder(entityType proto.EntityDTO_EntityType,
+	commTypes []proto.CommodityDTO_CommodityType) (*proto.TemplateDTO, error) {
+	builder := supplychain.NewSupplyChainNodeBuilder(entityType)
+	for _, commType := range commTypes {
+		ctype := commType
+		builder.Sells(&proto.TemplateCommodity{CommodityType: &ctype})
+	}
+	builder.SetPriority(-1)
+	builder.SetTemplateType(proto.TemplateDTO_BASE)
+
+	return builder.Create()
+}
+
+// optionalTemplateComms returns the commodities sold by some of the applications:
+// the database commodities, the JVM commodities, and the extra commodities
+func (f *SupplyChainFactory) optionalTemplateComms() []*proto.TemplateCommodity {
+	commTypes := append(append([]proto.CommodityDTO_CommodityType{}, dbCommodityTypes...), jvmCommodityTypes...)
+	return f.templateComms(append(commTypes, f.extraCommodities...))
+}
+
+// templateComms returns the keyed template commodities of the types, without duplicates
+func (f *SupplyChainFactory) templateComms(commTypes []proto.CommodityDTO_CommodityType) []*proto.TemplateCommodity {
+	comms := []*proto.TemplateCommodity{}
+	seen := make(map[proto.CommodityDTO_CommodityType]bool)
+	for _, commType := range commTypes {
+		if seen[commType] {
+			continue
+		}
+		seen[commType] = true
+
+		ctype := commType
+		comms = append(comms, &proto.TemplateCommodity{
+			CommodityType: &ctype,
+			Key:           &key,
+		})
+	}
+	return comms
+}